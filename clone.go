@@ -0,0 +1,116 @@
+package defaults
+
+import (
+	"reflect"
+	"unsafe"
+)
+
+// cloneValue returns a deep copy of v, e.g. to compare a struct with its former value.
+func cloneValue(v reflect.Value) reflect.Value {
+	c := reflect.New(v.Type()).Elem()
+	copyValue(c, v)
+	return c
+}
+
+// copyValue sets a deep copy of src to dst, including the unexported fields of structs,
+// so that setters called on the copy cannot modify the values referenced by src.
+// A value referenced by more than one pointer or map is copied once, which also keeps cycles.
+// Channels, funcs and unsafe pointers are shared, and so is the location of a time.Time.
+func copyValue(dst, src reflect.Value) {
+	c := &copier{seen: make(map[copied]reflect.Value)}
+	c.copy(dst, src)
+}
+
+type copier struct {
+	seen map[copied]reflect.Value
+}
+
+// copied identifies a value referenced by a pointer or a map.
+type copied struct {
+	ptr uintptr
+	typ reflect.Type
+}
+
+func (c *copier) copy(dst, src reflect.Value) {
+	switch src.Kind() {
+	case reflect.Ptr:
+		if src.IsNil() {
+			dst.Set(src)
+			return
+		}
+		key := copied{ptr: src.Pointer(), typ: src.Type()}
+		if p, ok := c.seen[key]; ok {
+			dst.Set(p)
+			return
+		}
+		p := reflect.New(src.Type().Elem())
+		c.seen[key] = p
+		c.copy(p.Elem(), src.Elem())
+		dst.Set(p)
+	case reflect.Interface:
+		if src.IsNil() {
+			dst.Set(src)
+			return
+		}
+		e := reflect.New(src.Elem().Type()).Elem()
+		c.copy(e, src.Elem())
+		dst.Set(e)
+	case reflect.Slice:
+		if src.IsNil() {
+			dst.Set(src)
+			return
+		}
+		s := reflect.MakeSlice(src.Type(), src.Len(), src.Len())
+		for i := 0; i < src.Len(); i++ {
+			c.copy(s.Index(i), src.Index(i))
+		}
+		dst.Set(s)
+	case reflect.Array:
+		for i := 0; i < src.Len(); i++ {
+			c.copy(dst.Index(i), src.Index(i))
+		}
+	case reflect.Map:
+		if src.IsNil() {
+			dst.Set(src)
+			return
+		}
+		key := copied{ptr: src.Pointer(), typ: src.Type()}
+		if m, ok := c.seen[key]; ok {
+			dst.Set(m)
+			return
+		}
+		m := reflect.MakeMapWithSize(src.Type(), src.Len())
+		c.seen[key] = m
+		iter := src.MapRange()
+		for iter.Next() {
+			v := reflect.New(src.Type().Elem()).Elem()
+			c.copy(v, iter.Value())
+			m.SetMapIndex(iter.Key(), v)
+		}
+		dst.Set(m)
+	case reflect.Struct:
+		if src.Type() == timeType {
+			dst.Set(src)
+			return
+		}
+		if !src.CanAddr() {
+			// unexported fields are read through their addresses
+			tmp := reflect.New(src.Type()).Elem()
+			tmp.Set(src)
+			src = tmp
+		}
+		for i := 0; i < src.NumField(); i++ {
+			c.copy(unrestricted(dst.Field(i)), unrestricted(src.Field(i)))
+		}
+	default:
+		dst.Set(src)
+	}
+}
+
+// unrestricted returns a field of an addressable struct which can be read and set even if it is unexported.
+func unrestricted(f reflect.Value) reflect.Value {
+	if f.CanSet() {
+		return f
+	}
+	return reflect.NewAt(f.Type(), unsafe.Pointer(f.UnsafeAddr())).Elem()
+}
@@ -0,0 +1,1116 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"go/types"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultsPath   = "github.com/creasty/defaults"
+	defaultTagName = "default"
+	layoutName     = "layout"
+)
+
+// generator writes SetDefaults methods for the struct types of a package.
+type generator struct {
+	pkg     *types.Package
+	tagName string
+	targets map[*types.TypeName]bool
+	imports map[string]string // names of imported packages by path
+	buf     bytes.Buffer
+	depth   int // the nesting of loops, which names their variables
+}
+
+// generate returns the source of a file declaring SetDefaults for the struct types named by names,
+// or for every struct type of pkg with default values if names is empty.
+func generate(pkg *types.Package, tagName string, names []string) ([]byte, error) {
+	g := &generator{
+		pkg:     pkg,
+		tagName: tagName,
+		targets: make(map[*types.TypeName]bool),
+		imports: make(map[string]string),
+	}
+
+	var targets []*types.TypeName
+	if len(names) == 0 {
+		scope := pkg.Scope()
+		for _, name := range scope.Names() {
+			tn, ok := scope.Lookup(name).(*types.TypeName)
+			if ok && g.generatable(tn) == nil && g.needs(tn.Type()) {
+				targets = append(targets, tn)
+			}
+		}
+	} else {
+		for _, name := range names {
+			tn, ok := pkg.Scope().Lookup(name).(*types.TypeName)
+			if !ok {
+				return nil, fmt.Errorf("type %s not found in package %s", name, pkg.Name())
+			}
+			if err := g.generatable(tn); err != nil {
+				return nil, err
+			}
+			targets = append(targets, tn)
+		}
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no struct type with default values in package %s", pkg.Name())
+	}
+	for _, tn := range targets {
+		g.targets[tn] = true
+	}
+
+	for _, tn := range targets {
+		if err := g.genType(tn); err != nil {
+			return nil, err
+		}
+	}
+
+	var src bytes.Buffer
+	fmt.Fprintf(&src, "// Code generated by defaults-gen; DO NOT EDIT.\n\npackage %s\n\n", pkg.Name())
+	if len(g.imports) > 0 {
+		paths := make([]string, 0, len(g.imports))
+		for path := range g.imports {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+		src.WriteString("import (\n")
+		for _, path := range paths {
+			fmt.Fprintf(&src, "%q\n", path)
+		}
+		src.WriteString(")\n\n")
+	}
+	src.Write(g.buf.Bytes())
+	return format.Source(src.Bytes())
+}
+
+// generatable returns an error if a SetDefaults method cannot be generated for tn.
+func (g *generator) generatable(tn *types.TypeName) error {
+	named, ok := tn.Type().(*types.Named)
+	if !ok || tn.IsAlias() {
+		return fmt.Errorf("%s is not a defined type", tn.Name())
+	}
+	if _, ok := named.Underlying().(*types.Struct); !ok {
+		return fmt.Errorf("%s is not a struct type", tn.Name())
+	}
+	if named.TypeParams().Len() > 0 {
+		return fmt.Errorf("%s is a generic type", tn.Name())
+	}
+	if hasMethod(named, "SetDefaults") {
+		return fmt.Errorf("%s already has a SetDefaults method", tn.Name())
+	}
+	return nil
+}
+
+func (g *generator) genType(tn *types.TypeName) error {
+	g.depth = 0
+	g.printf("// SetDefaults sets the default values given by `%s` tags.\n", g.tagName)
+	g.printf("func (s *%s) SetDefaults() {\n", tn.Name())
+	if err := g.genFields("s", tn.Type().Underlying().(*types.Struct)); err != nil {
+		return fmt.Errorf("%s: %v", tn.Name(), err)
+	}
+	g.printf("}\n\n")
+	g.printf("// GeneratedDefaults implements defaults.GeneratedSetter.\n")
+	g.printf("func (*%s) GeneratedDefaults() string { return %q }\n\n", tn.Name(), g.tagName)
+	return nil
+}
+
+func (g *generator) genFields(x string, st *types.Struct) error {
+	for i := 0; i < st.NumFields(); i++ {
+		f := st.Field(i)
+		if !f.Exported() {
+			continue
+		}
+		tag := reflect.StructTag(st.Tag(i))
+		val := tag.Get(g.tagName)
+		if val == "-" {
+			continue
+		}
+		if err := g.genField(selector(x, f.Name()), f.Type(), val, tag.Get(layoutName)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// genField writes the code setting the default value of x like setField of the defaults package.
+func (g *generator) genField(x string, t types.Type, tag, layout string) error {
+	if isNamed(t, "time", "Time") {
+		return g.genTime(x, tag, layout)
+	}
+	if tag != "" {
+		if text, json := unmarshalers(t); text || json {
+			return g.genUnmarshal(x, t, tag, text, json)
+		}
+	}
+
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		if tag == "" {
+			return nil
+		}
+		lit, err := g.basicLiteral(t, u, tag)
+		if err != nil {
+			return fmt.Errorf("%s: %v", x, err)
+		}
+		zero, _ := g.zeroCheck(x, t)
+		g.printf("if %s {\n%s = %s\n}\n", zero, x, lit)
+
+	case *types.Pointer:
+		elem := u.Elem()
+		if _, ok := elem.Underlying().(*types.Struct); ok {
+			if tag == "" && !g.needs(elem) {
+				return nil
+			}
+			if tag != "" {
+				g.printf("if %s == nil {\n%s = new(%s)\n}\n", x, x, g.typ(elem))
+			}
+			g.printf("if %s != nil {\n", x)
+			if err := g.genField("*"+x, elem, tag, layout); err != nil {
+				return err
+			}
+			g.printf("}\n")
+			return nil
+		}
+		if tag == "" {
+			return nil
+		}
+		g.printf("if %s == nil {\n%s = new(%s)\n", x, x, g.typ(elem))
+		if err := g.genField("*"+x, elem, tag, layout); err != nil {
+			return err
+		}
+		g.genSetter(x, t)
+		g.printf("}\n")
+
+	case *types.Struct:
+		if tag != "" && tag != "{}" {
+			if err := g.genAssign(x, t, tag); err != nil {
+				return err
+			}
+		}
+		return g.genNested(x, t)
+
+	case *types.Slice:
+		if tag != "" {
+			if tag == "[]" {
+				g.printf("if %s == nil {\n%s = %s{}\n}\n", x, x, g.typ(t))
+			} else if err := g.genAssign(x, t, tag); err != nil {
+				return err
+			}
+		}
+		return g.genElems(x, u.Elem())
+
+	case *types.Array:
+		if tag != "" && tag != "[]" {
+			if err := g.genAssign(x, t, tag); err != nil {
+				return err
+			}
+		}
+		return g.genElems(x, u.Elem())
+
+	case *types.Map:
+		if tag != "" {
+			if tag == "{}" {
+				g.printf("if %s == nil {\n%s = %s{}\n}\n", x, x, g.typ(t))
+			} else if err := g.genAssign(x, t, tag); err != nil {
+				return err
+			}
+		}
+		return g.genMapElems(x, u.Elem())
+
+	case *types.Interface:
+		if tag == "" {
+			return nil
+		}
+		if u.NumMethods() > 0 {
+			return fmt.Errorf("%s: registered types of %s are not supported", x, g.typ(t))
+		}
+		v, err := decodeJSON(tag)
+		if err != nil {
+			return fmt.Errorf("%s: %v", x, err)
+		}
+		if v != nil {
+			g.printf("if %s == nil {\n%s = %s\n}\n", x, x, genericLiteral(v))
+		}
+
+	default:
+		if tag != "" {
+			return fmt.Errorf("%s: unsupported type %s", x, g.typ(t))
+		}
+	}
+	return nil
+}
+
+// genAssign writes the code assigning the JSON value of tag to x if x is zero.
+func (g *generator) genAssign(x string, t types.Type, tag string) error {
+	v, err := decodeJSON(tag)
+	if err != nil {
+		return fmt.Errorf("%s: %v", x, err)
+	}
+	lit, err := g.literal(t, v, false)
+	if err != nil {
+		return fmt.Errorf("%s: %v", x, err)
+	}
+	zero, err := g.zeroCheck(x, t)
+	if err != nil {
+		return fmt.Errorf("%s: %v", x, err)
+	}
+	g.printf("if %s {\n%s = %s\n}\n", zero, x, lit)
+	return nil
+}
+
+// genNested writes the code setting the fields of a nested struct and calling its setter.
+func (g *generator) genNested(x string, t types.Type) error {
+	if st, ok := t.(*types.Struct); ok {
+		return g.genFields(x, st)
+	}
+	if named, ok := t.(*types.Named); ok && (g.targets[named.Obj()] || hasMethod(named, "GeneratedDefaults")) {
+		g.printf("%s.SetDefaults()\n", selector(x, ""))
+		return nil
+	}
+	if g.needs(t) {
+		pkg := g.use(defaultsPath, "defaults")
+		if g.tagName == defaultTagName {
+			g.printf("_ = %s.Set(%s)\n", pkg, addr(x))
+		} else {
+			g.printf("_ = %s.SetWithOptions(%s, %s.TagName(%q))\n", pkg, addr(x), pkg, g.tagName)
+		}
+	}
+	return nil
+}
+
+// genSetter writes the code calling the setter implemented by a pointer x.
+func (g *generator) genSetter(x string, t types.Type) {
+	sig := methodSig(t, "SetDefaults")
+	if sig == nil {
+		return
+	}
+	if sig.Results().Len() > 0 {
+		g.printf("_ = %s.SetDefaults()\n", x)
+	} else {
+		g.printf("%s.SetDefaults()\n", x)
+	}
+}
+
+// genElems writes the code setting the elements of a slice or an array.
+func (g *generator) genElems(x string, elem types.Type) error {
+	if !g.needs(elem) {
+		return nil
+	}
+	i := g.loopVar("i")
+	g.printf("for %s := range %s {\n", i, x)
+	g.depth++
+	err := g.genField(index(x, i), elem, "", "")
+	g.depth--
+	g.printf("}\n")
+	return err
+}
+
+// genMapElems writes the code setting the values of a map.
+func (g *generator) genMapElems(x string, elem types.Type) error {
+	k, v := g.loopVar("k"), g.loopVar("v")
+	g.depth++
+	defer func() { g.depth-- }()
+
+	if p, ok := elem.Underlying().(*types.Pointer); ok {
+		if !isComposite(p.Elem()) || !g.needs(p.Elem()) {
+			return nil
+		}
+		g.printf("for _, %s := range %s {\nif %s != nil {\n", v, x, v)
+		if err := g.genField("*"+v, p.Elem(), "", ""); err != nil {
+			return err
+		}
+		g.printf("}\n}\n")
+		return nil
+	}
+	if !isComposite(elem) || !g.needs(elem) {
+		return nil
+	}
+	g.printf("for %s, %s := range %s {\n", k, v, x)
+	if err := g.genField(v, elem, "", ""); err != nil {
+		return err
+	}
+	g.printf("%s[%s] = %s\n}\n", x, k, v)
+	return nil
+}
+
+// genUnmarshal writes the code decoding tag by the methods of an unmarshaler,
+// falling back on a value parsed by its kind.
+func (g *generator) genUnmarshal(x string, t types.Type, tag string, text, json bool) error {
+	zero, err := g.zeroCheck(x, t)
+	if err != nil {
+		return fmt.Errorf("%s: %v", x, err)
+	}
+	var calls []string
+	if text {
+		calls = append(calls, fmt.Sprintf("%s.UnmarshalText([]byte(%q))", selector(x, ""), tag))
+	}
+	if json && tag != "{}" && tag != "[]" {
+		calls = append(calls, fmt.Sprintf("%s.UnmarshalJSON([]byte(%q))", selector(x, ""), tag))
+	}
+
+	g.printf("if %s {\n", zero)
+	if b, ok := t.Underlying().(*types.Basic); ok {
+		if lit, err := g.basicLiteral(t, b, tag); err == nil {
+			g.printf("if %s != nil {\n%s = %s\n}\n}\n", strings.Join(calls, " != nil && "), x, lit)
+			return nil
+		}
+	}
+	if len(calls) == 1 {
+		g.printf("_ = %s\n}\n", calls[0])
+	} else {
+		g.printf("if %s != nil {\n_ = %s\n}\n}\n", calls[0], calls[1])
+	}
+	return nil
+}
+
+// genTime writes the code setting a time.Time like setTime of the defaults package.
+func (g *generator) genTime(x, tag, layout string) error {
+	if tag == "" {
+		return nil
+	}
+	pkg := g.use("time", "time")
+	g.printf("if %s == (%s.Time{}) {\n", x, pkg)
+	defer g.printf("}\n")
+
+	for _, base := range []string{"now", "today"} {
+		if !strings.HasPrefix(tag, base) {
+			continue
+		}
+		add := ""
+		if offset := tag[len(base):]; offset != "" {
+			d, err := time.ParseDuration(offset)
+			if err != nil {
+				return fmt.Errorf("%s: %v", x, err)
+			}
+			add = fmt.Sprintf(".Add(%s)", g.durationLiteral(d))
+		}
+		if base == "now" {
+			g.printf("%s = %s.Now()%s\n", x, pkg, add)
+		} else {
+			g.printf("y, m, d := %s.Now().Date()\n", pkg)
+			g.printf("%s = %s.Date(y, m, d, 0, 0, 0, 0, %s.Local)%s\n", x, pkg, pkg, add)
+		}
+		return nil
+	}
+
+	if layout != "" {
+		if _, err := time.Parse(layout, tag); err != nil {
+			return fmt.Errorf("%s: %v", x, err)
+		}
+		g.printf("%s, _ = %s.Parse(%q, %q)\n", x, pkg, layout, tag)
+		return nil
+	}
+	if sec, err := strconv.ParseInt(tag, 10, 64); err == nil {
+		g.printf("%s = %s.Unix(%d, 0)\n", x, pkg, sec)
+		return nil
+	}
+	if _, err := time.Parse(time.RFC3339, tag); err != nil {
+		return fmt.Errorf("%s: %v", x, err)
+	}
+	g.printf("%s, _ = %s.Parse(%s.RFC3339, %q)\n", x, pkg, pkg, tag)
+	return nil
+}
+
+// needs reports whether setting a value of t with an empty tag does anything.
+func (g *generator) needs(t types.Type) bool {
+	return g.needsSeen(t, make(map[types.Type]bool))
+}
+
+func (g *generator) needsSeen(t types.Type, seen map[types.Type]bool) bool {
+	if seen[t] || isNamed(t, "time", "Time") {
+		return false
+	}
+	seen[t] = true
+
+	switch u := t.Underlying().(type) {
+	case *types.Struct:
+		if named, ok := t.(*types.Named); ok && (g.targets[named.Obj()] || hasMethod(named, "SetDefaults")) {
+			return true
+		}
+		for i := 0; i < u.NumFields(); i++ {
+			if !u.Field(i).Exported() {
+				continue
+			}
+			if tag := reflect.StructTag(u.Tag(i)).Get(g.tagName); tag != "" && tag != "-" {
+				return true
+			}
+			if g.needsSeen(u.Field(i).Type(), seen) {
+				return true
+			}
+		}
+	case *types.Pointer:
+		if _, ok := u.Elem().Underlying().(*types.Struct); ok {
+			return g.needsSeen(u.Elem(), seen)
+		}
+	case *types.Slice:
+		return g.needsSeen(u.Elem(), seen)
+	case *types.Array:
+		return g.needsSeen(u.Elem(), seen)
+	case *types.Map:
+		elem := u.Elem()
+		if p, ok := elem.Underlying().(*types.Pointer); ok {
+			elem = p.Elem()
+		}
+		return isComposite(elem) && g.needsSeen(elem, seen)
+	}
+	return false
+}
+
+// zeroCheck returns an expression reporting whether x holds the zero value of t.
+func (g *generator) zeroCheck(x string, t types.Type) (string, error) {
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		if u.Info()&types.IsBoolean != 0 {
+			return "!" + x, nil
+		}
+		return fmt.Sprintf("%s == %s", x, zeroLiteral(u)), nil
+	case *types.Struct:
+		if types.Comparable(t) && !hasInterface(t) {
+			return fmt.Sprintf("%s == (%s{})", x, g.typ(t)), nil
+		}
+		var checks []string
+		for i := 0; i < u.NumFields(); i++ {
+			f := u.Field(i)
+			if f.Name() == "_" {
+				continue
+			}
+			if !f.Exported() && f.Pkg() != g.pkg {
+				return "", fmt.Errorf("zero value of %s cannot be checked", g.typ(t))
+			}
+			check, err := g.zeroCheck(selector(x, f.Name()), f.Type())
+			if err != nil {
+				return "", err
+			}
+			checks = append(checks, check)
+		}
+		if len(checks) == 0 {
+			return "true", nil
+		}
+		return strings.Join(checks, " && "), nil
+	case *types.Array:
+		if types.Comparable(t) && !hasInterface(t) {
+			return fmt.Sprintf("%s == (%s{})", x, g.typ(t)), nil
+		}
+		return "", fmt.Errorf("zero value of %s cannot be checked", g.typ(t))
+	}
+	return fmt.Sprintf("%s == nil", x), nil
+}
+
+// basicLiteral returns the literal of tag parsed like parseValue of the defaults package.
+func (g *generator) basicLiteral(t types.Type, u *types.Basic, tag string) (string, error) {
+	bits := basicBits(u)
+	switch info := u.Info(); {
+	case info&types.IsBoolean != 0:
+		v, err := strconv.ParseBool(tag)
+		return strconv.FormatBool(v), err
+	case info&types.IsString != 0:
+		return strconv.Quote(tag), nil
+	case info&types.IsUnsigned != 0:
+		v, err := strconv.ParseUint(tag, 0, bits)
+		return strconv.FormatUint(v, 10), err
+	case info&types.IsInteger != 0:
+		if isNamed(t, "time", "Duration") {
+			if d, err := time.ParseDuration(tag); err == nil {
+				return g.durationLiteral(d), nil
+			}
+		}
+		v, err := strconv.ParseInt(tag, 0, bits)
+		return strconv.FormatInt(v, 10), err
+	case info&types.IsFloat != 0:
+		v, err := strconv.ParseFloat(tag, bits)
+		if err != nil {
+			return "", err
+		}
+		return floatLiteral(v, bits)
+	case info&types.IsComplex != 0:
+		v, err := strconv.ParseComplex(tag, bits)
+		if err != nil {
+			return "", err
+		}
+		re, err := floatLiteral(real(v), bits/2)
+		if err != nil {
+			return "", err
+		}
+		im, err := floatLiteral(imag(v), bits/2)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("complex(%s, %s)", re, im), nil
+	}
+	return "", fmt.Errorf("unsupported type %s", g.typ(t))
+}
+
+// literal returns a composite literal of t holding a value decoded from JSON like encoding/json.
+// The type of the literal is left out if elided.
+func (g *generator) literal(t types.Type, v interface{}, elided bool) (string, error) {
+	if text, json := unmarshalers(t); text || json {
+		return "", fmt.Errorf("%s implements an unmarshaler, which is not supported in a JSON value", g.typ(t))
+	}
+	typ := g.typ(t)
+	if elided {
+		typ = ""
+	}
+
+	if v == nil {
+		switch u := t.Underlying().(type) {
+		case *types.Basic:
+			return zeroLiteral(u), nil
+		case *types.Struct, *types.Array:
+			return typ + "{}", nil
+		}
+		return "nil", nil
+	}
+
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		return g.jsonBasicLiteral(t, u, v)
+
+	case *types.Pointer:
+		lit, err := g.literal(u.Elem(), v, elided && isComposite(u.Elem()))
+		if err != nil {
+			return "", err
+		}
+		if isComposite(u.Elem()) {
+			if elided {
+				return lit, nil
+			}
+			return "&" + lit, nil
+		}
+		return fmt.Sprintf("func() %s { v := (%s)(%s); return &v }()", g.typ(t), g.typ(u.Elem()), lit), nil
+
+	case *types.Slice:
+		if s, ok := v.(string); ok && isByte(u.Elem()) {
+			b, err := base64.StdEncoding.DecodeString(s)
+			if err != nil {
+				return "", err
+			}
+			elems := make([]string, len(b))
+			for i, c := range b {
+				elems[i] = strconv.Itoa(int(c))
+			}
+			return typ + "{" + strings.Join(elems, ", ") + "}", nil
+		}
+		arr, ok := v.([]interface{})
+		if !ok {
+			return "", jsonTypeError(v, g.typ(t))
+		}
+		elems, err := g.literals(u.Elem(), arr)
+		if err != nil {
+			return "", err
+		}
+		return typ + "{" + strings.Join(elems, ", ") + "}", nil
+
+	case *types.Array:
+		arr, ok := v.([]interface{})
+		if !ok {
+			return "", jsonTypeError(v, g.typ(t))
+		}
+		if int64(len(arr)) > u.Len() {
+			arr = arr[:u.Len()]
+		}
+		elems, err := g.literals(u.Elem(), arr)
+		if err != nil {
+			return "", err
+		}
+		return typ + "{" + strings.Join(elems, ", ") + "}", nil
+
+	case *types.Map:
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return "", jsonTypeError(v, g.typ(t))
+		}
+		keys := sortedKeys(obj)
+		elems := make([]string, 0, len(obj))
+		for _, k := range keys {
+			key, err := g.mapKeyLiteral(u.Key(), k)
+			if err != nil {
+				return "", err
+			}
+			val, err := g.literal(u.Elem(), obj[k], true)
+			if err != nil {
+				return "", err
+			}
+			elems = append(elems, key+": "+val)
+		}
+		return typ + "{" + strings.Join(elems, ", ") + "}", nil
+
+	case *types.Struct:
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return "", jsonTypeError(v, g.typ(t))
+		}
+		lit := &structLiteral{typ: t}
+		fields := jsonFields(u, g.pkg)
+		for _, k := range sortedKeys(obj) {
+			f := matchField(fields, k)
+			if f == nil {
+				continue
+			}
+			val, err := g.literal(f.typ, obj[k], false)
+			if err != nil {
+				return "", err
+			}
+			lit.set(f.path, val)
+		}
+		return lit.render(g, elided)
+
+	case *types.Interface:
+		if u.NumMethods() > 0 {
+			return "", fmt.Errorf("unsupported type %s", g.typ(t))
+		}
+		return genericLiteral(v), nil
+	}
+	return "", fmt.Errorf("unsupported type %s", g.typ(t))
+}
+
+func (g *generator) literals(elem types.Type, arr []interface{}) ([]string, error) {
+	elems := make([]string, len(arr))
+	for i, e := range arr {
+		lit, err := g.literal(elem, e, true)
+		if err != nil {
+			return nil, err
+		}
+		elems[i] = lit
+	}
+	return elems, nil
+}
+
+func (g *generator) jsonBasicLiteral(t types.Type, u *types.Basic, v interface{}) (string, error) {
+	bits := basicBits(u)
+	switch info := u.Info(); {
+	case info&types.IsBoolean != 0:
+		if b, ok := v.(bool); ok {
+			return strconv.FormatBool(b), nil
+		}
+	case info&types.IsString != 0:
+		if s, ok := v.(string); ok {
+			return strconv.Quote(s), nil
+		}
+	case info&types.IsUnsigned != 0:
+		if n, ok := v.(json.Number); ok {
+			val, err := strconv.ParseUint(string(n), 10, bits)
+			return strconv.FormatUint(val, 10), err
+		}
+	case info&types.IsInteger != 0:
+		if n, ok := v.(json.Number); ok {
+			val, err := strconv.ParseInt(string(n), 10, bits)
+			return strconv.FormatInt(val, 10), err
+		}
+	case info&types.IsFloat != 0:
+		if n, ok := v.(json.Number); ok {
+			val, err := strconv.ParseFloat(string(n), bits)
+			if err != nil {
+				return "", err
+			}
+			return floatLiteral(val, bits)
+		}
+	}
+	return "", jsonTypeError(v, g.typ(t))
+}
+
+func (g *generator) mapKeyLiteral(t types.Type, k string) (string, error) {
+	if u, ok := t.Underlying().(*types.Basic); ok {
+		switch info := u.Info(); {
+		case info&types.IsString != 0:
+			return strconv.Quote(k), nil
+		case info&types.IsUnsigned != 0:
+			v, err := strconv.ParseUint(k, 10, basicBits(u))
+			return strconv.FormatUint(v, 10), err
+		case info&types.IsInteger != 0:
+			v, err := strconv.ParseInt(k, 10, basicBits(u))
+			return strconv.FormatInt(v, 10), err
+		}
+	}
+	return "", fmt.Errorf("unsupported map key type %s", g.typ(t))
+}
+
+// durationLiteral returns an expression of d in the largest unit dividing it.
+func (g *generator) durationLiteral(d time.Duration) string {
+	pkg := g.use("time", "time")
+	units := []struct {
+		name string
+		d    time.Duration
+	}{
+		{"Hour", time.Hour},
+		{"Minute", time.Minute},
+		{"Second", time.Second},
+		{"Millisecond", time.Millisecond},
+		{"Microsecond", time.Microsecond},
+	}
+	for _, u := range units {
+		if d != 0 && d%u.d == 0 {
+			if n := d / u.d; n != 1 {
+				return fmt.Sprintf("%d * %s.%s", n, pkg, u.name)
+			}
+			return pkg + "." + u.name
+		}
+	}
+	return fmt.Sprintf("%s.Duration(%d)", pkg, int64(d))
+}
+
+// typ returns the name of t qualified by the packages it imports.
+func (g *generator) typ(t types.Type) string {
+	return types.TypeString(t, func(p *types.Package) string {
+		if p == g.pkg {
+			return ""
+		}
+		return g.use(p.Path(), p.Name())
+	})
+}
+
+// use imports a package and returns its name.
+func (g *generator) use(path, name string) string {
+	g.imports[path] = name
+	return name
+}
+
+func (g *generator) loopVar(prefix string) string {
+	return prefix + strconv.Itoa(g.depth)
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+// structLiteral is a struct literal whose fields, including promoted ones, are set one by one.
+type structLiteral struct {
+	typ    types.Type
+	fields []structLiteralField
+}
+
+type structLiteralField struct {
+	index int
+	name  string
+	value string
+	embed *structLiteral // the literal of an embedded struct holding a promoted field
+	ptr   bool           // the embedded struct is referenced by a pointer
+}
+
+func (l *structLiteral) set(path []int, value string) {
+	st := l.typ.Underlying().(*types.Struct)
+	f := st.Field(path[0])
+	field := l.field(path[0], f.Name())
+	if len(path) == 1 {
+		field.value = value
+		return
+	}
+	if field.embed == nil {
+		typ := f.Type()
+		if p, ok := typ.Underlying().(*types.Pointer); ok {
+			typ, field.ptr = p.Elem(), true
+		}
+		field.embed = &structLiteral{typ: typ}
+	}
+	field.embed.set(path[1:], value)
+}
+
+func (l *structLiteral) field(index int, name string) *structLiteralField {
+	for i := range l.fields {
+		if l.fields[i].index == index {
+			return &l.fields[i]
+		}
+	}
+	l.fields = append(l.fields, structLiteralField{index: index, name: name})
+	return &l.fields[len(l.fields)-1]
+}
+
+func (l *structLiteral) render(g *generator, elided bool) (string, error) {
+	sort.Slice(l.fields, func(i, j int) bool { return l.fields[i].index < l.fields[j].index })
+	elems := make([]string, len(l.fields))
+	for i, f := range l.fields {
+		value := f.value
+		if f.embed != nil {
+			lit, err := f.embed.render(g, false)
+			if err != nil {
+				return "", err
+			}
+			if f.ptr {
+				lit = "&" + lit
+			}
+			value = lit
+		}
+		elems[i] = f.name + ": " + value
+	}
+	typ := g.typ(l.typ)
+	if elided {
+		typ = ""
+	}
+	return typ + "{" + strings.Join(elems, ", ") + "}", nil
+}
+
+// jsonField is a field of a struct decoded by encoding/json.
+type jsonField struct {
+	name   string
+	path   []int // the indices of the field and the embedded structs promoting it
+	typ    types.Type
+	tagged bool
+}
+
+// jsonFields returns the fields of st visible to encoding/json, resolving promoted fields by depth.
+func jsonFields(st *types.Struct, pkg *types.Package) []jsonField {
+	var all []jsonField
+	var walk func(st *types.Struct, path []int, seen map[*types.Struct]bool)
+	walk = func(st *types.Struct, path []int, seen map[*types.Struct]bool) {
+		if seen[st] {
+			return
+		}
+		seen[st] = true
+		for i := 0; i < st.NumFields(); i++ {
+			f := st.Field(i)
+			name, _, _ := strings.Cut(reflect.StructTag(st.Tag(i)).Get("json"), ",")
+			if name == "-" {
+				continue
+			}
+			p := append(append([]int(nil), path...), i)
+			if f.Embedded() && name == "" {
+				typ := f.Type()
+				if ptr, ok := typ.Underlying().(*types.Pointer); ok {
+					typ = ptr.Elem()
+				}
+				if est, ok := typ.Underlying().(*types.Struct); ok && (f.Exported() || f.Pkg() == pkg) {
+					walk(est, p, seen)
+					continue
+				}
+			}
+			if !f.Exported() {
+				continue
+			}
+			field := jsonField{name: name, path: p, typ: f.Type(), tagged: name != ""}
+			if name == "" {
+				field.name = f.Name()
+			}
+			all = append(all, field)
+		}
+	}
+	walk(st, nil, make(map[*types.Struct]bool))
+
+	var fields []jsonField
+	for _, f := range all {
+		dominant := true
+		for _, o := range all {
+			if o.name != f.name || (o.path[0] == f.path[0] && len(o.path) == len(f.path)) {
+				continue
+			}
+			if len(o.path) < len(f.path) || (len(o.path) == len(f.path) && (o.tagged || !f.tagged)) {
+				dominant = false
+				break
+			}
+		}
+		if dominant {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// matchField returns the field named by a key of a JSON object, preferring an exact match.
+func matchField(fields []jsonField, key string) *jsonField {
+	for i := range fields {
+		if fields[i].name == key {
+			return &fields[i]
+		}
+	}
+	for i := range fields {
+		if strings.EqualFold(fields[i].name, key) {
+			return &fields[i]
+		}
+	}
+	return nil
+}
+
+// genericLiteral returns the literal of a JSON value decoded into an empty interface.
+func genericLiteral(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "nil"
+	case bool:
+		return strconv.FormatBool(v)
+	case string:
+		return strconv.Quote(v)
+	case json.Number:
+		f, _ := strconv.ParseFloat(string(v), 64)
+		return fmt.Sprintf("float64(%s)", strconv.FormatFloat(f, 'g', -1, 64))
+	case []interface{}:
+		elems := make([]string, len(v))
+		for i, e := range v {
+			elems[i] = genericLiteral(e)
+		}
+		return "[]interface{}{" + strings.Join(elems, ", ") + "}"
+	case map[string]interface{}:
+		elems := make([]string, 0, len(v))
+		for _, k := range sortedKeys(v) {
+			elems = append(elems, strconv.Quote(k)+": "+genericLiteral(v[k]))
+		}
+		return "map[string]interface{}{" + strings.Join(elems, ", ") + "}"
+	}
+	panic(fmt.Sprintf("unexpected JSON value %T", v))
+}
+
+// decodeJSON decodes a JSON value keeping numbers as written.
+func decodeJSON(s string) (interface{}, error) {
+	if !json.Valid([]byte(s)) {
+		return nil, fmt.Errorf("invalid JSON value %q", s)
+	}
+	dec := json.NewDecoder(strings.NewReader(s))
+	dec.UseNumber()
+	var v interface{}
+	err := dec.Decode(&v)
+	return v, err
+}
+
+func jsonTypeError(v interface{}, typ string) error {
+	kind := "object"
+	switch v.(type) {
+	case bool:
+		kind = "bool"
+	case string:
+		kind = "string"
+	case json.Number:
+		kind = "number"
+	case []interface{}:
+		kind = "array"
+	}
+	return fmt.Errorf("cannot decode JSON %s into %s", kind, typ)
+}
+
+func floatLiteral(v float64, bits int) (string, error) {
+	if math.IsInf(v, 0) || math.IsNaN(v) {
+		return "", fmt.Errorf("%v is not supported", v)
+	}
+	return strconv.FormatFloat(v, 'g', -1, bits), nil
+}
+
+func zeroLiteral(u *types.Basic) string {
+	switch info := u.Info(); {
+	case info&types.IsBoolean != 0:
+		return "false"
+	case info&types.IsString != 0:
+		return `""`
+	case info&types.IsNumeric != 0:
+		return "0"
+	}
+	return "nil"
+}
+
+// basicBits returns the bit size of a numeric type as given to strconv.
+func basicBits(u *types.Basic) int {
+	switch u.Kind() {
+	case types.Int8, types.Uint8:
+		return 8
+	case types.Int16, types.Uint16:
+		return 16
+	case types.Int32, types.Uint32, types.Float32:
+		return 32
+	case types.Complex64:
+		return 64
+	case types.Complex128:
+		return 128
+	case types.Int, types.Uint, types.Uintptr:
+		return strconv.IntSize
+	}
+	return 64
+}
+
+// unmarshalers reports which of encoding.TextUnmarshaler and json.Unmarshaler a pointer to t implements.
+func unmarshalers(t types.Type) (text, json bool) {
+	p := types.NewPointer(t)
+	return methodSig(p, "UnmarshalText") != nil, methodSig(p, "UnmarshalJSON") != nil
+}
+
+// methodSig returns the signature of a method in the method set of t.
+func methodSig(t types.Type, name string) *types.Signature {
+	sel := types.NewMethodSet(t).Lookup(nil, name)
+	if sel == nil {
+		return nil
+	}
+	return sel.Type().(*types.Signature)
+}
+
+// hasMethod reports whether a pointer to t has a method.
+func hasMethod(t types.Type, name string) bool {
+	return methodSig(types.NewPointer(t), name) != nil
+}
+
+func hasInterface(t types.Type) bool {
+	switch u := t.Underlying().(type) {
+	case *types.Interface:
+		return true
+	case *types.Array:
+		return hasInterface(u.Elem())
+	case *types.Struct:
+		for i := 0; i < u.NumFields(); i++ {
+			if hasInterface(u.Field(i).Type()) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isNamed(t types.Type, path, name string) bool {
+	named, ok := t.(*types.Named)
+	return ok && named.Obj().Pkg() != nil && named.Obj().Pkg().Path() == path && named.Obj().Name() == name
+}
+
+// isComposite reports whether the values of t are written in composite literals.
+func isComposite(t types.Type) bool {
+	switch t.Underlying().(type) {
+	case *types.Struct, *types.Slice, *types.Array, *types.Map:
+		return true
+	}
+	return false
+}
+
+func isByte(t types.Type) bool {
+	b, ok := t.Underlying().(*types.Basic)
+	return ok && b.Kind() == types.Uint8
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// selector returns the selector of a field or a method of x, which may be a dereferenced pointer.
+// The selector of x itself is returned for an empty name.
+func selector(x, name string) string {
+	if strings.HasPrefix(x, "*") {
+		if strings.HasPrefix(x, "**") {
+			x = "(" + x + ")"
+		} else {
+			x = x[1:]
+		}
+	}
+	if name == "" {
+		return x
+	}
+	return x + "." + name
+}
+
+func index(x, i string) string {
+	if strings.HasPrefix(x, "*") {
+		x = "(" + x + ")"
+	}
+	return x + "[" + i + "]"
+}
+
+func addr(x string) string {
+	if strings.HasPrefix(x, "*") {
+		return x[1:]
+	}
+	return "&" + x
+}
@@ -0,0 +1,101 @@
+// Command defaults-gen generates SetDefaults methods which set the default values
+// declared by struct tags without reflection.
+//
+// It is run by go generate in the directory of a package:
+//
+//	//go:generate go run github.com/creasty/defaults/cmd/defaults-gen
+//
+// A SetDefaults method is generated for every struct type of the package with default values,
+// or for the types listed by the -type flag. The types implement defaults.GeneratedSetter,
+// and defaults.Set calls their SetDefaults instead of walking them with reflection.
+//
+// Default values are parsed when generating, so an invalid one is an error of the generator.
+// The generated code does not look up the types and parsers registered at run time,
+// and types not declared in the package are still set with defaults.Set,
+// or defaults.SetWithOptions with the tag name given by the -tag flag.
+// As SetDefaults returns nothing, the generated code drops the errors of these calls,
+// e.g. an invalid default value or an error of an ErrorSetter of such a type.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("defaults-gen: ")
+
+	typeNames := flag.String("type", "", "comma-separated list of type names; every struct type with default values if empty")
+	output := flag.String("output", "defaults_gen.go", "output file name")
+	tagName := flag.String("tag", defaultTagName, "key of struct tags holding default values")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: defaults-gen [flags] [directory]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	dir := "."
+	if flag.NArg() > 0 {
+		dir = flag.Arg(0)
+	}
+	var names []string
+	if *typeNames != "" {
+		names = strings.Split(*typeNames, ",")
+	}
+
+	src, err := run(dir, *output, *tagName, names)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, *output), src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run returns the source of the output file generated for the package in dir.
+func run(dir, output, tagName string, names []string) ([]byte, error) {
+	pkg, err := load(dir, output)
+	if err != nil {
+		return nil, err
+	}
+	return generate(pkg, tagName, names)
+}
+
+// load type-checks the package in dir, leaving out a previously generated output file.
+func load(dir, output string) (*types.Package, error) {
+	bp, err := build.ImportDir(dir, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for _, name := range bp.GoFiles {
+		if name == output {
+			continue
+		}
+		f, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+
+	path := bp.ImportPath
+	if path == "" || path == "." {
+		path = bp.Name
+	}
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	return conf.Check(path, fset, files, nil)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	dir := filepath.Join("..", "..", "internal", "gentest")
+	src, err := run(dir, "defaults_gen.go", "default", nil)
+	if err != nil {
+		t.Fatalf("it should not return an error: %v", err)
+	}
+	want, err := os.ReadFile(filepath.Join(dir, "defaults_gen.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(src, want) {
+		t.Errorf("it should generate the same code as internal/gentest/defaults_gen.go; run go generate ./...")
+	}
+}
+
+func TestGenerateTagName(t *testing.T) {
+	dir := t.TempDir()
+	src := "package p\n\ntype H struct { A int `def:\"1\"` }\n\ntype T struct {\n\tB int `def:\"2\"`\n\tH H\n}\n"
+	if err := os.WriteFile(filepath.Join(dir, "p.go"), []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out, err := run(dir, "defaults_gen.go", "def", []string{"T"})
+	if err != nil {
+		t.Fatalf("it should not return an error: %v", err)
+	}
+	if want := `_ = defaults.SetWithOptions(&s.H, defaults.TagName("def"))`; !bytes.Contains(out, []byte(want)) {
+		t.Errorf("it should set a type without generated code with the tag name, want %s in\n%s", want, out)
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	cases := []struct {
+		src   string
+		names []string
+		err   string
+	}{
+		{"type T struct { A int `default:\"x\"` }", nil, `T: s.A: strconv.ParseInt: parsing "x": invalid syntax`},
+		{"type T struct { A uint8 `default:\"256\"` }", nil, "value out of range"},
+		{"type T struct { A []int `default:\"[\\\"a\\\"]\"` }", nil, "cannot decode JSON string into int"},
+		{"type T struct { A interface{ M() } `default:\"gzip\"` }", nil, "registered types of interface{M()} are not supported"},
+		{"type T struct { A int }", nil, "no struct type with default values"},
+		{"type T struct { A int }\nfunc (*T) SetDefaults() {}", []string{"T"}, "T already has a SetDefaults method"},
+		{"type T int", []string{"T"}, "T is not a struct type"},
+		{"type T struct{}", []string{"U"}, "type U not found"},
+	}
+
+	for _, c := range cases {
+		dir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dir, "p.go"), []byte("package p\n\n"+c.src+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := run(dir, "defaults_gen.go", "default", c.names)
+		if err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("it should fail with %q for %s, got %v", c.err, c.src, err)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package defaults
+
+import "reflect"
+
+var defaultDefaulter = NewDefaulter()
+
+// Defaulter sets default values with a fixed set of options.
+// It is safe for concurrent use by multiple goroutines.
+type Defaulter struct {
+	options options
+	plans   *planCache
+}
+
+// NewDefaulter returns a Defaulter configured by options.
+func NewDefaulter(opts ...Option) *Defaulter {
+	d := &Defaulter{options: options{tagName: fieldName}}
+	for _, opt := range opts {
+		opt(&d.options)
+	}
+	d.plans = planCacheFor(&d.options)
+	return d
+}
+
+// Set initializes members in a struct referenced by a pointer like the package-level Set.
+func (d *Defaulter) Set(ptr interface{}) error {
+	w := d.walker()
+	if err := w.set(ptr); err != nil {
+		return err
+	}
+	if len(w.errs) > 0 {
+		return w.errs
+	}
+	return w.validate(reflect.ValueOf(ptr).Elem())
+}
+
+// apply sets default values like Set without checking required fields,
+// e.g. to a new struct holding only default values.
+func (d *Defaulter) apply(ptr interface{}) error {
+	w := d.walker()
+	if err := w.set(ptr); err != nil {
+		return err
+	}
+	if len(w.errs) > 0 {
+		return w.errs
+	}
+	return nil
+}
+
+func (d *Defaulter) walker() *walker {
+	return &walker{options: d.options, plans: d.plans, path: make(fieldPath, 0, 8)}
+}
+
+// MustSet calls Set and panics if it returns an error.
+func (d *Defaulter) MustSet(ptr interface{}) {
+	if err := d.Set(ptr); err != nil {
+		panic(err)
+	}
+}
@@ -3,175 +3,203 @@ package defaults
 import (
 	"encoding"
 	"encoding/json"
-	"errors"
+	"fmt"
 	"reflect"
 	"strconv"
 	"time"
 )
 
-var (
-	errInvalidType = errors.New("not a struct pointer")
-)
-
 const (
-	fieldName = "default"
+	fieldName  = "default"
+	layoutName = "layout"
 )
 
 // Set initializes members in a struct referenced by a pointer.
 // Maps and slices are initialized by `make` and other primitive types are set with default values.
 // `ptr` should be a struct pointer
 func Set(ptr interface{}) error {
-	if reflect.TypeOf(ptr).Kind() != reflect.Ptr {
-		return errInvalidType
+	return defaultDefaulter.Set(ptr)
+}
+
+// SetWithOptions is like Set but its behavior can be customized with options.
+func SetWithOptions(ptr interface{}, opts ...Option) error {
+	return NewDefaulter(opts...).Set(ptr)
+}
+
+// MustSet function is a wrapper of Set function
+// It will call Set and panic if err not equals nil.
+func MustSet(ptr interface{}) {
+	if err := Set(ptr); err != nil {
+		panic(err)
 	}
+}
 
-	v := reflect.ValueOf(ptr).Elem()
-	t := v.Type()
+// walker applies default values to a struct tree with a set of options.
+type walker struct {
+	options
+	plans       *planCache
+	path        fieldPath
+	errs        Errors
+	sources     []Source            // the sources overriding fields, if any
+	tags        []reflect.StructTag // the tags of the fields in the path while overriding by a source or env
+	origins     Origins             // the origins of values set, if they are recorded
+	reports     []reportEntry       // the fields reported by SetWithReport
+	reportIndex map[string]int      // the indices of reports by path, nil unless fields are reported
+	force       bool                // fields with default values are reset regardless of their values
+	noSetters   bool                // setters are not called, e.g. until JSON is decoded
+}
 
-	if t.Kind() != reflect.Struct {
-		return errInvalidType
+func (w *walker) set(ptr interface{}) error {
+	v, err := structOf(ptr)
+	if err != nil {
+		return err
 	}
+	return w.setStruct(v)
+}
 
-	for i := 0; i < t.NumField(); i++ {
-		if defaultVal := t.Field(i).Tag.Get(fieldName); defaultVal != "-" {
-			if err := setField(v.Field(i), defaultVal); err != nil {
-				return err
-			}
+// structOf returns the struct referenced by a pointer.
+func structOf(ptr interface{}) (reflect.Value, error) {
+	if ptr == nil || reflect.TypeOf(ptr).Kind() != reflect.Ptr {
+		return reflect.Value{}, ErrInvalidType
+	}
+	if reflect.ValueOf(ptr).IsNil() {
+		return reflect.Value{}, ErrNilPointer
+	}
+
+	v := reflect.ValueOf(ptr).Elem()
+	if v.Kind() != reflect.Struct {
+		return reflect.Value{}, ErrInvalidType
+	}
+	return v, nil
+}
+
+// setStruct sets default values to the fields of an addressable struct and calls its setter.
+func (w *walker) setStruct(v reflect.Value) error {
+	if gs, ok := v.Addr().Interface().(GeneratedSetter); ok && w.useGenerated(gs) {
+		gs.SetDefaults()
+		return nil
+	}
+	plan := w.plans.planOf(v.Type())
+	for i := range plan.fields {
+		f := &plan.fields[i]
+		w.pushField(f.name)
+		err := w.setStructField(v.Field(f.index), f)
+		w.pop()
+		if err != nil {
+			return err
 		}
 	}
-	callSetter(ptr)
-	return nil
+	return w.callSetter(v.Addr().Interface())
 }
 
-// MustSet function is a wrapper of Set function
-// It will call Set and panic if err not equals nil.
-func MustSet(ptr interface{}) {
-	if err := Set(ptr); err != nil {
-		panic(err)
+// useGenerated reports whether the generated SetDefaults of gs sets the same values as the walk.
+// It is not used with Strict or AllErrors, since it drops the errors of nested types set by Set.
+func (w *walker) useGenerated(gs GeneratedSetter) bool {
+	if w.ignoreGenerated || w.strict || w.allErrors || w.force || w.env || w.noSetters || w.origins != nil || w.reportIndex != nil {
+		return false
 	}
+	return w.now == nil && !w.int64AsDuration && gs.GeneratedDefaults() == w.tagName
 }
 
-func setField(field reflect.Value, defaultVal string) error {
+// fieldTag holds the tags of a struct field used to set its default value.
+type fieldTag struct {
+	value  string      // the default value
+	layout string      // the layout of a time.Time value
+	env    string      // the name of an environment variable overriding the value
+	cache  *valueCache // the cache of parsed values, nil unless the tag is of a struct field
+}
+
+func (w *walker) setField(field reflect.Value, tag fieldTag) error {
 	if !field.CanSet() {
 		return nil
 	}
 
-	if !shouldInitializeField(field, defaultVal) {
+	if w.force && tag.value != "" {
+		field.Set(reflect.Zero(field.Type()))
+	}
+
+	if w.env && tag.env != "" {
+		// the value of the variable is set with its defaults, so the default value must not be applied over it
+		if overridden, err := w.setEnv(field, tag); err != nil || overridden {
+			return err
+		}
+	}
+
+	if !shouldInitializeField(field, tag.value) {
 		return nil
 	}
 
 	isInitial := isInitialValue(field)
-	if isInitial {
-		if unmarshalByInterface(field, defaultVal) {
+	if !isInitial {
+		w.recordPreserved(field, tag.value)
+	} else {
+		if parse, ok := lookupParser(field.Type()); ok {
+			set, err := w.setParsed(field, tag.value, parse)
+			if err != nil {
+				return w.report(newFieldError(w.path.String(), tag.value, field.Type(), err))
+			}
+			if set {
+				w.recordDefault(field, tag.value)
+			}
 			return nil
 		}
 
-		switch field.Kind() {
-		case reflect.Bool:
-			if val, err := strconv.ParseBool(defaultVal); err == nil {
-				field.Set(reflect.ValueOf(val).Convert(field.Type()))
-			}
-		case reflect.Int:
-			if val, err := strconv.ParseInt(defaultVal, 0, strconv.IntSize); err == nil {
-				field.Set(reflect.ValueOf(int(val)).Convert(field.Type()))
-			}
-		case reflect.Int8:
-			if val, err := strconv.ParseInt(defaultVal, 0, 8); err == nil {
-				field.Set(reflect.ValueOf(int8(val)).Convert(field.Type()))
-			}
-		case reflect.Int16:
-			if val, err := strconv.ParseInt(defaultVal, 0, 16); err == nil {
-				field.Set(reflect.ValueOf(int16(val)).Convert(field.Type()))
-			}
-		case reflect.Int32:
-			if val, err := strconv.ParseInt(defaultVal, 0, 32); err == nil {
-				field.Set(reflect.ValueOf(int32(val)).Convert(field.Type()))
-			}
-		case reflect.Int64:
-			if val, err := time.ParseDuration(defaultVal); err == nil {
-				field.Set(reflect.ValueOf(val).Convert(field.Type()))
-			} else if val, err := strconv.ParseInt(defaultVal, 0, 64); err == nil {
-				field.Set(reflect.ValueOf(val).Convert(field.Type()))
-			}
-		case reflect.Uint:
-			if val, err := strconv.ParseUint(defaultVal, 0, strconv.IntSize); err == nil {
-				field.Set(reflect.ValueOf(uint(val)).Convert(field.Type()))
-			}
-		case reflect.Uint8:
-			if val, err := strconv.ParseUint(defaultVal, 0, 8); err == nil {
-				field.Set(reflect.ValueOf(uint8(val)).Convert(field.Type()))
-			}
-		case reflect.Uint16:
-			if val, err := strconv.ParseUint(defaultVal, 0, 16); err == nil {
-				field.Set(reflect.ValueOf(uint16(val)).Convert(field.Type()))
-			}
-		case reflect.Uint32:
-			if val, err := strconv.ParseUint(defaultVal, 0, 32); err == nil {
-				field.Set(reflect.ValueOf(uint32(val)).Convert(field.Type()))
-			}
-		case reflect.Uint64:
-			if val, err := strconv.ParseUint(defaultVal, 0, 64); err == nil {
-				field.Set(reflect.ValueOf(val).Convert(field.Type()))
-			}
-		case reflect.Uintptr:
-			if val, err := strconv.ParseUint(defaultVal, 0, strconv.IntSize); err == nil {
-				field.Set(reflect.ValueOf(uintptr(val)).Convert(field.Type()))
-			}
-		case reflect.Float32:
-			if val, err := strconv.ParseFloat(defaultVal, 32); err == nil {
-				field.Set(reflect.ValueOf(float32(val)).Convert(field.Type()))
+		if field.Type() == timeType {
+			if err := w.setTime(field, tag); err != nil {
+				return w.report(newFieldError(w.path.String(), tag.value, field.Type(), err))
 			}
-		case reflect.Float64:
-			if val, err := strconv.ParseFloat(defaultVal, 64); err == nil {
-				field.Set(reflect.ValueOf(val).Convert(field.Type()))
-			}
-		case reflect.String:
-			field.Set(reflect.ValueOf(defaultVal).Convert(field.Type()))
-
-		case reflect.Slice:
-			ref := reflect.New(field.Type())
-			ref.Elem().Set(reflect.MakeSlice(field.Type(), 0, 0))
-			if defaultVal != "" && defaultVal != "[]" {
-				if err := json.Unmarshal([]byte(defaultVal), ref.Interface()); err != nil {
-					return err
-				}
-			}
-			field.Set(ref.Elem().Convert(field.Type()))
-		case reflect.Map:
-			ref := reflect.New(field.Type())
-			ref.Elem().Set(reflect.MakeMap(field.Type()))
-			if defaultVal != "" && defaultVal != "{}" {
-				if err := json.Unmarshal([]byte(defaultVal), ref.Interface()); err != nil {
-					return err
-				}
-			}
-			field.Set(ref.Elem().Convert(field.Type()))
-		case reflect.Struct:
-			if defaultVal != "" && defaultVal != "{}" {
-				if err := json.Unmarshal([]byte(defaultVal), field.Addr().Interface()); err != nil {
-					return err
-				}
-			}
-		case reflect.Ptr:
-			field.Set(reflect.New(field.Type().Elem()))
+			w.recordDefault(field, tag.value)
+			return nil
+		}
+
+		if unmarshalByInterface(field, tag.value) {
+			w.recordDefault(field, tag.value)
+			return nil
+		}
+
+		set, err := w.setValue(field, tag)
+		if err != nil {
+			return w.report(newFieldError(w.path.String(), tag.value, field.Type(), err))
+		}
+		if set {
+			w.recordDefault(field, tag.value)
 		}
 	}
 
 	switch field.Kind() {
 	case reflect.Ptr:
 		if isInitial || field.Elem().Kind() == reflect.Struct {
-			setField(field.Elem(), defaultVal)
-			callSetter(field.Interface())
+			w.pushDeref()
+			err := w.setField(field.Elem(), tag)
+			w.pop()
+			if err != nil {
+				return err
+			}
+			if err := w.callSetter(field.Interface()); err != nil {
+				return err
+			}
 		}
 	case reflect.Struct:
-		if err := Set(field.Addr().Interface()); err != nil {
+		if err := w.setStruct(field); err != nil {
 			return err
 		}
-	case reflect.Slice:
+	case reflect.Slice, reflect.Array:
 		for j := 0; j < field.Len(); j++ {
-			if err := setField(field.Index(j), ""); err != nil {
+			w.pushIndex(j)
+			err := w.setField(field.Index(j), fieldTag{})
+			w.pop()
+			if err != nil {
+				return err
+			}
+		}
+	case reflect.Interface:
+		if isInitial && !field.IsNil() {
+			ref := reflect.New(field.Elem().Type())
+			ref.Elem().Set(field.Elem())
+			if err := w.setField(ref.Elem(), fieldTag{}); err != nil {
 				return err
 			}
+			field.Set(ref.Elem())
 		}
 	case reflect.Map:
 		for _, e := range field.MapKeys() {
@@ -180,15 +208,21 @@ func setField(field reflect.Value, defaultVal string) error {
 			switch v.Kind() {
 			case reflect.Ptr:
 				switch v.Elem().Kind() {
-				case reflect.Struct, reflect.Slice, reflect.Map:
-					if err := setField(v.Elem(), ""); err != nil {
+				case reflect.Struct, reflect.Slice, reflect.Array, reflect.Map:
+					w.pushKey(e)
+					err := w.setField(v.Elem(), fieldTag{})
+					w.pop()
+					if err != nil {
 						return err
 					}
 				}
-			case reflect.Struct, reflect.Slice, reflect.Map:
+			case reflect.Struct, reflect.Slice, reflect.Array, reflect.Map:
 				ref := reflect.New(v.Type())
 				ref.Elem().Set(v)
-				if err := setField(ref.Elem(), ""); err != nil {
+				w.pushKey(e)
+				err := w.setField(ref.Elem(), fieldTag{})
+				w.pop()
+				if err != nil {
 					return err
 				}
 				field.SetMapIndex(e, ref.Elem().Convert(v.Type()))
@@ -199,6 +233,235 @@ func setField(field reflect.Value, defaultVal string) error {
 	return nil
 }
 
+// setValue decodes a default value into a field holding an initial value,
+// and reports whether a value was set, which is not when an invalid value is ignored.
+func (w *walker) setValue(field reflect.Value, tag fieldTag) (bool, error) {
+	switch field.Kind() {
+	case reflect.Ptr:
+		field.Set(reflect.New(field.Type().Elem()))
+		return true, nil
+	case reflect.Interface:
+		return true, w.setInterface(field, tag.value)
+	}
+
+	p := w.parse(field.Type(), tag)
+	if p.err != nil {
+		if !p.lenient {
+			return false, p.err
+		}
+		if err := w.parseError(p.err); err != nil {
+			return false, err
+		}
+	}
+	if !p.val.IsValid() {
+		return false, nil
+	}
+	field.Set(p.val)
+	return true, nil
+}
+
+// parse returns the default value parsed for a type, which is cached for a tag of a struct field.
+func (w *walker) parse(typ reflect.Type, tag fieldTag) parsedValue {
+	if tag.cache == nil {
+		return parseValue(typ, tag.value, w.int64AsDuration)
+	}
+	return tag.cache.parse(typ, tag.value, w.int64AsDuration)
+}
+
+// parsedValue is the result of parsing a default value for a type.
+type parsedValue struct {
+	val     reflect.Value // the value to set, or an invalid value if there is nothing to set
+	err     error
+	lenient bool // err is ignored unless the strict mode is enabled
+}
+
+func parsedScalar(val interface{}, typ reflect.Type, err error) parsedValue {
+	if err != nil {
+		return parsedValue{err: err, lenient: true}
+	}
+	return parsedValue{val: reflect.ValueOf(val).Convert(typ)}
+}
+
+// parseValue parses a default value for a type.
+// A slice, array, map or struct is decoded into a new value, so the value returned can be set to a field as is.
+func parseValue(typ reflect.Type, defaultVal string, int64AsDuration bool) parsedValue {
+	switch typ.Kind() {
+	case reflect.Bool:
+		val, err := strconv.ParseBool(defaultVal)
+		return parsedScalar(val, typ, err)
+	case reflect.Int:
+		val, err := strconv.ParseInt(defaultVal, 0, strconv.IntSize)
+		return parsedScalar(int(val), typ, err)
+	case reflect.Int8:
+		val, err := strconv.ParseInt(defaultVal, 0, 8)
+		return parsedScalar(int8(val), typ, err)
+	case reflect.Int16:
+		val, err := strconv.ParseInt(defaultVal, 0, 16)
+		return parsedScalar(int16(val), typ, err)
+	case reflect.Int32:
+		val, err := strconv.ParseInt(defaultVal, 0, 32)
+		return parsedScalar(int32(val), typ, err)
+	case reflect.Int64:
+		if typ == durationType || int64AsDuration {
+			if val, err := time.ParseDuration(defaultVal); err == nil {
+				return parsedScalar(val, typ, nil)
+			}
+		}
+		val, err := strconv.ParseInt(defaultVal, 0, 64)
+		return parsedScalar(val, typ, err)
+	case reflect.Uint:
+		val, err := strconv.ParseUint(defaultVal, 0, strconv.IntSize)
+		return parsedScalar(uint(val), typ, err)
+	case reflect.Uint8:
+		val, err := strconv.ParseUint(defaultVal, 0, 8)
+		return parsedScalar(uint8(val), typ, err)
+	case reflect.Uint16:
+		val, err := strconv.ParseUint(defaultVal, 0, 16)
+		return parsedScalar(uint16(val), typ, err)
+	case reflect.Uint32:
+		val, err := strconv.ParseUint(defaultVal, 0, 32)
+		return parsedScalar(uint32(val), typ, err)
+	case reflect.Uint64:
+		val, err := strconv.ParseUint(defaultVal, 0, 64)
+		return parsedScalar(val, typ, err)
+	case reflect.Uintptr:
+		val, err := strconv.ParseUint(defaultVal, 0, strconv.IntSize)
+		return parsedScalar(uintptr(val), typ, err)
+	case reflect.Float32:
+		val, err := strconv.ParseFloat(defaultVal, 32)
+		return parsedScalar(float32(val), typ, err)
+	case reflect.Float64:
+		val, err := strconv.ParseFloat(defaultVal, 64)
+		return parsedScalar(val, typ, err)
+	case reflect.Complex64:
+		val, err := strconv.ParseComplex(defaultVal, 64)
+		return parsedScalar(complex64(val), typ, err)
+	case reflect.Complex128:
+		val, err := strconv.ParseComplex(defaultVal, 128)
+		return parsedScalar(val, typ, err)
+	case reflect.String:
+		return parsedScalar(defaultVal, typ, nil)
+
+	case reflect.Slice:
+		ref := reflect.New(typ)
+		ref.Elem().Set(reflect.MakeSlice(typ, 0, 0))
+		if defaultVal != "" && defaultVal != "[]" {
+			if err := json.Unmarshal([]byte(defaultVal), ref.Interface()); err != nil {
+				return parsedValue{err: err}
+			}
+		}
+		return parsedValue{val: ref.Elem()}
+	case reflect.Array:
+		if defaultVal == "" || defaultVal == "[]" {
+			return parsedValue{}
+		}
+		ref := reflect.New(reflect.SliceOf(typ.Elem()))
+		if err := json.Unmarshal([]byte(defaultVal), ref.Interface()); err != nil {
+			return parsedValue{err: err}
+		}
+		arr := reflect.New(typ).Elem()
+		reflect.Copy(arr, ref.Elem())
+		if n := ref.Elem().Len(); n != typ.Len() {
+			return parsedValue{val: arr, err: fmt.Errorf("array of length %d given for %s", n, typ), lenient: true}
+		}
+		return parsedValue{val: arr}
+	case reflect.Map:
+		ref := reflect.New(typ)
+		ref.Elem().Set(reflect.MakeMap(typ))
+		if defaultVal != "" && defaultVal != "{}" {
+			if err := json.Unmarshal([]byte(defaultVal), ref.Interface()); err != nil {
+				return parsedValue{err: err}
+			}
+		}
+		return parsedValue{val: ref.Elem()}
+	case reflect.Struct:
+		if defaultVal == "" || defaultVal == "{}" {
+			return parsedValue{}
+		}
+		ref := reflect.New(typ)
+		if err := json.Unmarshal([]byte(defaultVal), ref.Interface()); err != nil {
+			return parsedValue{err: err}
+		}
+		return parsedValue{val: ref.Elem()}
+	}
+	return parsedValue{err: ErrUnsupportedKind, lenient: true}
+}
+
+// setInterface sets an interface field with a registered type or a JSON value.
+// The registry is looked up only for a non-empty interface, which not every type implements.
+func (w *walker) setInterface(field reflect.Value, defaultVal string) error {
+	if field.NumMethod() > 0 {
+		typ, ok := lookupType(defaultVal, field.Type())
+		if !ok {
+			return fmt.Errorf("no type registered as %q for %s", defaultVal, field.Type())
+		}
+		if typ.Kind() == reflect.Ptr {
+			field.Set(reflect.New(typ.Elem()))
+		} else {
+			field.Set(reflect.Zero(typ))
+		}
+		return nil
+	}
+	var val interface{}
+	if err := json.Unmarshal([]byte(defaultVal), &val); err != nil {
+		return err
+	}
+	if val != nil {
+		field.Set(reflect.ValueOf(val))
+	}
+	return nil
+}
+
+// setParsed sets a field with a value parsed by a registered ParseFunc, and reports whether a value was set.
+func (w *walker) setParsed(field reflect.Value, defaultVal string, parse ParseFunc) (bool, error) {
+	if defaultVal == "" {
+		return false, nil
+	}
+	val, err := parse(defaultVal)
+	if err != nil {
+		return false, w.parseError(err)
+	}
+	rv := reflect.ValueOf(val)
+	if !rv.IsValid() || !rv.Type().ConvertibleTo(field.Type()) {
+		return false, fmt.Errorf("parser returned %T for %s", val, field.Type())
+	}
+	field.Set(rv.Convert(field.Type()))
+	return true, nil
+}
+
+// callSetter calls the Setter or ErrorSetter implemented by v.
+func (w *walker) callSetter(v interface{}) error {
+	if w.noSetters {
+		return nil
+	}
+	call := callSetter
+	if w.reportIndex != nil && hasSetter(v) && reflect.TypeOf(v).Elem().Kind() == reflect.Struct {
+		call = w.callSetterReported
+	}
+	if err := call(v); err != nil {
+		return w.report(&FieldError{Path: w.path.String(), Type: reflect.TypeOf(v), Kind: ErrSetterFailed, Err: err})
+	}
+	return nil
+}
+
+// report returns err as is, or collects it to continue walking when all errors are requested.
+func (w *walker) report(err error) error {
+	if w.allErrors {
+		w.errs = append(w.errs, err)
+		return nil
+	}
+	return err
+}
+
+// parseError reports a failure to parse a default value.
+// It is ignored unless the strict mode is enabled.
+func (w *walker) parseError(err error) error {
+	if w.strict {
+		return err
+	}
+	return nil
+}
+
 func unmarshalByInterface(field reflect.Value, defaultVal string) bool {
 	asText, ok := field.Addr().Interface().(encoding.TextUnmarshaler)
 	if ok && defaultVal != "" {
@@ -218,7 +481,7 @@ func unmarshalByInterface(field reflect.Value, defaultVal string) bool {
 }
 
 func isInitialValue(field reflect.Value) bool {
-	return reflect.DeepEqual(reflect.Zero(field.Type()).Interface(), field.Interface())
+	return field.IsZero()
 }
 
 func shouldInitializeField(field reflect.Value, tag string) bool {
@@ -229,7 +492,7 @@ func shouldInitializeField(field reflect.Value, tag string) bool {
 		if !field.IsNil() && field.Elem().Kind() == reflect.Struct {
 			return true
 		}
-	case reflect.Slice:
+	case reflect.Slice, reflect.Array:
 		return field.Len() > 0 || tag != ""
 	case reflect.Map:
 		return field.Len() > 0 || tag != ""
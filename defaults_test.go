@@ -3,9 +3,13 @@ package defaults
 import (
 	"encoding/json"
 	"errors"
+	"flag"
+	"fmt"
+	"math/big"
 	"net"
 	"reflect"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -26,6 +30,7 @@ type (
 	MyUintptr uintptr
 	MyFloat32 float32
 	MyFloat64 float64
+	MyComplex complex128
 	MyBool    bool
 	MyString  string
 	MyMap     map[string]int
@@ -33,23 +38,25 @@ type (
 )
 
 type Sample struct {
-	Int       int           `default:"1"`
-	Int8      int8          `default:"8"`
-	Int16     int16         `default:"16"`
-	Int32     int32         `default:"32"`
-	Int64     int64         `default:"64"`
-	Uint      uint          `default:"1"`
-	Uint8     uint8         `default:"8"`
-	Uint16    uint16        `default:"16"`
-	Uint32    uint32        `default:"32"`
-	Uint64    uint64        `default:"64"`
-	Uintptr   uintptr       `default:"1"`
-	Float32   float32       `default:"1.32"`
-	Float64   float64       `default:"1.64"`
-	BoolTrue  bool          `default:"true"`
-	BoolFalse bool          `default:"false"`
-	String    string        `default:"hello"`
-	Duration  time.Duration `default:"10s"`
+	Int        int           `default:"1"`
+	Int8       int8          `default:"8"`
+	Int16      int16         `default:"16"`
+	Int32      int32         `default:"32"`
+	Int64      int64         `default:"64"`
+	Uint       uint          `default:"1"`
+	Uint8      uint8         `default:"8"`
+	Uint16     uint16        `default:"16"`
+	Uint32     uint32        `default:"32"`
+	Uint64     uint64        `default:"64"`
+	Uintptr    uintptr       `default:"1"`
+	Float32    float32       `default:"1.32"`
+	Float64    float64       `default:"1.64"`
+	Complex64  complex64     `default:"1+2i"`
+	Complex128 complex128    `default:"-1.5-0.5i"`
+	BoolTrue   bool          `default:"true"`
+	BoolFalse  bool          `default:"false"`
+	String     string        `default:"hello"`
+	Duration   time.Duration `default:"10s"`
 
 	IntOct    int    `default:"0o1"`
 	Int8Oct   int8   `default:"0o10"`
@@ -110,6 +117,7 @@ type Sample struct {
 	MyUintptr   MyUintptr `default:"1"`
 	MyFloat32   MyFloat32 `default:"1.32"`
 	MyFloat64   MyFloat64 `default:"1.64"`
+	MyComplex   MyComplex `default:"2i"`
 	MyBoolTrue  MyBool    `default:"true"`
 	MyBoolFalse MyBool    `default:"false"`
 	MyString    MyString  `default:"hello"`
@@ -300,6 +308,12 @@ func TestInit(t *testing.T) {
 		if sample.Float64 != 1.64 {
 			t.Errorf("it should initialize float64")
 		}
+		if sample.Complex64 != 1+2i {
+			t.Errorf("it should initialize complex64")
+		}
+		if sample.Complex128 != -1.5-0.5i {
+			t.Errorf("it should initialize complex128")
+		}
 		if sample.BoolTrue != true {
 			t.Errorf("it should initialize bool (true)")
 		}
@@ -483,6 +497,9 @@ func TestInit(t *testing.T) {
 		if sample.MyFloat64 != 1.64 {
 			t.Errorf("it should initialize float64")
 		}
+		if sample.MyComplex != 2i {
+			t.Errorf("it should initialize complex128")
+		}
 		if sample.MyBoolTrue != true {
 			t.Errorf("it should initialize bool (true)")
 		}
@@ -769,3 +786,1610 @@ func TestDefaultsSetter(t *testing.T) {
 		t.Errorf("expected 1 for MainInt, got %d", main.MainInt)
 	}
 }
+
+func TestStrict(t *testing.T) {
+	t.Run("valid defaults", func(t *testing.T) {
+		sample := &Sample{}
+		if err := SetWithOptions(sample, Strict()); err != nil {
+			t.Fatalf("it should not return an error: %v", err)
+		}
+		if sample.Int != 1 || sample.Duration != 10*time.Second {
+			t.Errorf("it should initialize fields as Set does")
+		}
+	})
+
+	t.Run("invalid defaults", func(t *testing.T) {
+		cases := map[string]interface{}{
+			"bool": &struct {
+				V bool `default:"yes"`
+			}{},
+			"int": &struct {
+				V int `default:"1O"`
+			}{},
+			"int8": &struct {
+				V int8 `default:"128"`
+			}{},
+			"int64": &struct {
+				V int64 `default:"1x"`
+			}{},
+			"uint": &struct {
+				V uint `default:"-1"`
+			}{},
+			"uint16": &struct {
+				V uint16 `default:"65536"`
+			}{},
+			"uintptr": &struct {
+				V uintptr `default:"ptr"`
+			}{},
+			"float32": &struct {
+				V float32 `default:"1e39"`
+			}{},
+			"float64": &struct {
+				V float64 `default:"1.2.3"`
+			}{},
+			"complex64": &struct {
+				V complex64 `default:"1+i+2"`
+			}{},
+			"complex128": &struct {
+				V complex128 `default:"1e309i"`
+			}{},
+			"aliased": &struct {
+				V MyInt `default:"one"`
+			}{},
+			"embedded": &struct {
+				S struct {
+					V int `default:"x"`
+				}
+			}{},
+		}
+		for name, ptr := range cases {
+			if err := Set(ptr); err != nil {
+				t.Errorf("%s: it should ignore an invalid default without the strict mode: %v", name, err)
+			}
+			if err := SetWithOptions(ptr, Strict()); err == nil {
+				t.Errorf("%s: it should return an error in the strict mode", name)
+			}
+		}
+	})
+}
+
+func TestFieldError(t *testing.T) {
+	type Listener struct {
+		Timeout int   `default:"1O"`
+		Ports   []int `default:"[!]"`
+	}
+	type Server struct {
+		Listeners []Listener
+	}
+	sample := &struct {
+		Server Server
+	}{
+		Server: Server{Listeners: []Listener{{Ports: []int{1}}, {}}},
+	}
+
+	err := SetWithOptions(sample, Strict())
+
+	var fieldErr *FieldError
+	if !errors.As(err, &fieldErr) {
+		t.Fatalf("it should return a FieldError: %v", err)
+	}
+	if fieldErr.Path != "Server.Listeners[0].Timeout" {
+		t.Errorf("it should record the path of the field: %s", fieldErr.Path)
+	}
+	if fieldErr.Tag != "1O" {
+		t.Errorf("it should record the raw tag value: %s", fieldErr.Tag)
+	}
+	if fieldErr.Type != reflect.TypeOf(0) {
+		t.Errorf("it should record the type of the field: %v", fieldErr.Type)
+	}
+	if !errors.Is(err, strconv.ErrSyntax) {
+		t.Errorf("it should unwrap to the underlying error: %v", fieldErr.Err)
+	}
+
+	err = Set(sample)
+	if !errors.As(err, &fieldErr) || fieldErr.Path != "Server.Listeners[1].Ports" {
+		t.Errorf("it should record the index of the element: %v", err)
+	}
+}
+
+func TestAllErrors(t *testing.T) {
+	sample := &struct {
+		Int    int            `default:"x"`
+		Slice  []int          `default:"[!]"`
+		Map    map[string]int `default:"{!}"`
+		String string         `default:"ok"`
+		Nested struct {
+			Uint uint8 `default:"256"`
+		}
+	}{}
+
+	err := SetWithOptions(sample, Strict(), AllErrors())
+
+	var errs Errors
+	if !errors.As(err, &errs) {
+		t.Fatalf("it should return Errors: %v", err)
+	}
+	var paths []string
+	for _, e := range errs {
+		var fieldErr *FieldError
+		if !errors.As(e, &fieldErr) {
+			t.Fatalf("it should collect FieldErrors: %v", e)
+		}
+		paths = append(paths, fieldErr.Path)
+	}
+	if expected := []string{"Int", "Slice", "Map", "Nested.Uint"}; !reflect.DeepEqual(paths, expected) {
+		t.Errorf("it should collect all errors, got %v, expected %v", paths, expected)
+	}
+	if !errors.Is(err, strconv.ErrRange) {
+		t.Errorf("it should be compatible with errors.Is")
+	}
+	if !errs.Is(strconv.ErrRange) || errs.Is(ErrNilPointer) {
+		t.Errorf("it should match the collected errors without a multi-error Unwrap")
+	}
+	var fieldErr *FieldError
+	if !errs.As(&fieldErr) || fieldErr.Path != "Int" {
+		t.Errorf("it should find the first collected error without a multi-error Unwrap: %v", fieldErr)
+	}
+	if sample.String != "ok" {
+		t.Errorf("it should keep walking after an error")
+	}
+
+	if err := SetWithOptions(&struct {
+		Int int `default:"1"`
+	}{}, AllErrors()); err != nil {
+		t.Errorf("it should return nil without errors: %v", err)
+	}
+}
+
+func TestPointerFieldError(t *testing.T) {
+	type Inner struct {
+		I []int `default:"[!]"`
+	}
+	cases := []struct {
+		ptr  interface{}
+		path string
+	}{
+		{&struct {
+			Slice *[]int `default:"[!]"`
+		}{}, "(*Slice)"},
+		{&struct {
+			Struct *Inner `default:"{!}"`
+		}{}, "(*Struct)"},
+		{&struct {
+			Struct *Inner `default:"{}"`
+		}{}, "(*Struct).I"},
+		{&struct {
+			Struct *Inner
+		}{Struct: &Inner{}}, "(*Struct).I"},
+	}
+	for _, c := range cases {
+		err := Set(c.ptr)
+		var fieldErr *FieldError
+		if !errors.As(err, &fieldErr) {
+			t.Errorf("%s: it should return a FieldError: %v", c.path, err)
+			continue
+		}
+		if fieldErr.Path != c.path {
+			t.Errorf("it should include the pointer hop in the path, got %s, expected %s", fieldErr.Path, c.path)
+		}
+	}
+}
+
+type failingSetter struct {
+	Int int `default:"1"`
+}
+
+func (s *failingSetter) SetDefaults() error {
+	return errors.New("failed")
+}
+
+func TestErrorKinds(t *testing.T) {
+	var nilPtr *Sample
+	nonStruct := 1
+
+	cases := []struct {
+		name string
+		err  error
+		kind error
+	}{
+		{"untyped nil", Set(nil), ErrInvalidType},
+		{"non-pointer", Set(Sample{}), ErrInvalidType},
+		{"non-struct", Set(&nonStruct), ErrInvalidType},
+		{"nil pointer", Set(nilPtr), ErrNilPointer},
+		{"parse", SetWithOptions(&struct {
+			I int `default:"x"`
+		}{}, Strict()), ErrParse},
+		{"json", Set(&struct {
+			I []int `default:"[!]"`
+		}{}), ErrParse},
+		{"overflow", SetWithOptions(&struct {
+			I int8 `default:"1000"`
+		}{}, Strict()), ErrOverflow},
+		{"unsupported kind", SetWithOptions(&struct {
+			C chan int `default:"1"`
+		}{}, Strict()), ErrUnsupportedKind},
+		{"setter", Set(&struct {
+			S failingSetter
+		}{}), ErrSetterFailed},
+	}
+	for _, c := range cases {
+		if !errors.Is(c.err, c.kind) {
+			t.Errorf("%s: it should be %v, got %v", c.name, c.kind, c.err)
+		}
+	}
+
+	if err := Set(&struct {
+		C chan int `default:"1"`
+	}{}); err != nil {
+		t.Errorf("it should ignore an unsupported kind without the strict mode: %v", err)
+	}
+
+	var fieldErr *FieldError
+	if err := Set(&failingSetter{}); !errors.As(err, &fieldErr) || fieldErr.Err.Error() != "failed" {
+		t.Errorf("it should wrap the error of an ErrorSetter: %v", err)
+	}
+}
+
+func TestArray(t *testing.T) {
+	sample := &struct {
+		Ports        [3]int    `default:"[80, 443, 8080]"`
+		Short        [3]int    `default:"[1]"`
+		Empty        [2]string `default:"[]"`
+		Structs      [2]Struct `default:"[{\"Foo\": 1}]"`
+		StructsNoTag [1]Struct
+		Ptrs         [2]*Struct            `default:"[{}, {\"Foo\": 2}]"`
+		NonInitial   [2]int                `default:"[1, 2]"`
+		Nested       [1][2]Struct          `default:"[[{}]]"`
+		MapValues    map[string][2]Struct  `default:"{\"a\": [{}]}"`
+		MapPtrs      map[string]*[1]Struct `default:"{\"a\": [{}]}"`
+	}{
+		NonInitial: [2]int{0, 9},
+	}
+
+	if err := Set(sample); err != nil {
+		t.Fatalf("it should not return an error: %v", err)
+	}
+	if sample.Ports != [3]int{80, 443, 8080} {
+		t.Errorf("it should initialize an array with json")
+	}
+	if sample.Short != [3]int{1, 0, 0} {
+		t.Errorf("it should initialize leading elements of an array with a shorter json")
+	}
+	if sample.Empty != [2]string{} {
+		t.Errorf("it should leave an array with an empty default")
+	}
+	if !reflect.DeepEqual(sample.Structs, [2]Struct{
+		{Embedded: Embedded{Int: 1}, Foo: 1, Bar: 456, WithDefault: "foo"},
+		{Embedded: Embedded{Int: 1}, Bar: 456, WithDefault: "foo"},
+	}) {
+		t.Errorf("it should recurse into elements of an array of structs: %+v", sample.Structs)
+	}
+	if sample.StructsNoTag[0].WithDefault != "foo" || sample.StructsNoTag[0].Bar != 456 {
+		t.Errorf("it should recurse into an array of structs without a tag")
+	}
+	if sample.Ptrs[0] == nil || sample.Ptrs[0].Bar != 456 || sample.Ptrs[1] == nil || sample.Ptrs[1].Foo != 2 {
+		t.Errorf("it should initialize an array of pointers")
+	}
+	if sample.NonInitial != [2]int{0, 9} {
+		t.Errorf("it should not override a non-initial array")
+	}
+	if sample.Nested[0][0].WithDefault != "foo" || sample.Nested[0][1].WithDefault != "foo" {
+		t.Errorf("it should recurse into nested arrays")
+	}
+	if v := sample.MapValues["a"]; v[0].WithDefault != "foo" || v[1].WithDefault != "foo" {
+		t.Errorf("it should recurse into arrays in map values: %+v", v)
+	}
+	if p := sample.MapPtrs["a"]; p == nil || p[0].WithDefault != "foo" {
+		t.Errorf("it should recurse into pointers to arrays in map values: %+v", p)
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		if err := Set(&struct {
+			A [2]int `default:"[!]"`
+		}{}); !errors.Is(err, ErrParse) {
+			t.Errorf("it should return an error for invalid json: %v", err)
+		}
+
+		mismatch := &struct {
+			A [2]int `default:"[1, 2, 3]"`
+		}{}
+		if err := Set(mismatch); err != nil || mismatch.A != [2]int{1, 2} {
+			t.Errorf("it should ignore a length mismatch without the strict mode: %v", err)
+		}
+		if err := SetWithOptions(&struct {
+			A [2]int `default:"[1, 2, 3]"`
+		}{}, Strict()); !errors.Is(err, ErrParse) {
+			t.Errorf("it should return an error for a length mismatch in the strict mode: %v", err)
+		}
+	})
+}
+
+type Encoder interface {
+	Encode() string
+}
+
+type GzipEncoder struct {
+	Level int `default:"6"`
+	Name  string
+}
+
+func (e *GzipEncoder) Encode() string { return "gzip" }
+
+func (e *GzipEncoder) SetDefaults() {
+	e.Name = "gzip"
+}
+
+type PlainEncoder struct {
+	Prefix string `default:">"`
+}
+
+func (e PlainEncoder) Encode() string { return "plain" }
+
+func TestInterface(t *testing.T) {
+	Register("gzip", &GzipEncoder{})
+	Register("plain", PlainEncoder{})
+
+	sample := &struct {
+		Any        interface{} `default:"{\"a\": 1}"`
+		AnySlice   interface{} `default:"[1, \"b\"]"`
+		AnyString  interface{} `default:"\"str\""`
+		AnyNull    interface{} `default:"null"`
+		AnyNoTag   interface{} // should be left nil
+		Codec      Encoder     `default:"gzip"`
+		ValueCodec Encoder     `default:"plain"`
+		NonInitial Encoder     `default:"gzip"`
+	}{
+		NonInitial: PlainEncoder{Prefix: "!"},
+	}
+
+	if err := Set(sample); err != nil {
+		t.Fatalf("it should not return an error: %v", err)
+	}
+	if !reflect.DeepEqual(sample.Any, map[string]interface{}{"a": float64(1)}) {
+		t.Errorf("it should decode json into an empty interface: %#v", sample.Any)
+	}
+	if !reflect.DeepEqual(sample.AnySlice, []interface{}{float64(1), "b"}) {
+		t.Errorf("it should decode a json array into an empty interface: %#v", sample.AnySlice)
+	}
+	if sample.AnyString != "str" {
+		t.Errorf("it should decode a json string into an empty interface: %#v", sample.AnyString)
+	}
+	if sample.AnyNull != nil || sample.AnyNoTag != nil {
+		t.Errorf("it should leave an empty interface nil")
+	}
+	if codec, ok := sample.Codec.(*GzipEncoder); !ok || codec.Level != 6 || codec.Name != "gzip" {
+		t.Errorf("it should instantiate and default a registered pointer type: %#v", sample.Codec)
+	}
+	if codec, ok := sample.ValueCodec.(PlainEncoder); !ok || codec.Prefix != ">" {
+		t.Errorf("it should instantiate and default a registered value type: %#v", sample.ValueCodec)
+	}
+	if sample.NonInitial != (PlainEncoder{Prefix: "!"}) {
+		t.Errorf("it should not override a non-initial interface")
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		if err := Set(&struct {
+			Codec Encoder `default:"zstd"`
+		}{}); !errors.Is(err, ErrParse) {
+			t.Errorf("it should return an error for an unregistered name: %v", err)
+		}
+		if err := Set(&struct {
+			Codec fmt.Stringer `default:"gzip"`
+		}{}); !errors.Is(err, ErrParse) {
+			t.Errorf("it should return an error for a type not implementing the interface: %v", err)
+		}
+		if err := Set(&struct {
+			Any interface{} `default:"{!}"`
+		}{}); !errors.Is(err, ErrParse) {
+			t.Errorf("it should return an error for invalid json: %v", err)
+		}
+		if err := Set(&struct {
+			Any interface{} `default:"gzip"`
+		}{}); !errors.Is(err, ErrParse) {
+			t.Errorf("it should not look up registered types for an empty interface: %v", err)
+		}
+	})
+}
+
+func TestTime(t *testing.T) {
+	loc := time.FixedZone("JST", 9*60*60)
+	now := time.Date(2024, 8, 13, 15, 30, 0, 0, loc)
+	clock := Clock(func() time.Time { return now })
+
+	sample := &struct {
+		Now        time.Time  `default:"now"`
+		Later      time.Time  `default:"now+24h"`
+		Earlier    time.Time  `default:"now-1h30m"`
+		Today      time.Time  `default:"today"`
+		Tomorrow   time.Time  `default:"today+24h"`
+		Epoch      time.Time  `default:"1700000000"`
+		RFC3339    time.Time  `default:"2024-01-02T03:04:05Z"`
+		Layout     time.Time  `default:"2024-01-02" layout:"2006-01-02"`
+		Ptr        *time.Time `default:"02 Jan 24 15:04 UTC" layout:"02 Jan 06 15:04 MST"`
+		NoTag      time.Time
+		NonInitial time.Time `default:"now"`
+	}{
+		NonInitial: time.Unix(1, 0),
+	}
+
+	if err := SetWithOptions(sample, clock); err != nil {
+		t.Fatalf("it should not return an error: %v", err)
+	}
+	if !sample.Now.Equal(now) {
+		t.Errorf("it should set the current time: %v", sample.Now)
+	}
+	if !sample.Later.Equal(now.Add(24 * time.Hour)) {
+		t.Errorf("it should set a time relative to the current time: %v", sample.Later)
+	}
+	if !sample.Earlier.Equal(now.Add(-90 * time.Minute)) {
+		t.Errorf("it should set a time relative to the current time: %v", sample.Earlier)
+	}
+	if !sample.Today.Equal(time.Date(2024, 8, 13, 0, 0, 0, 0, loc)) {
+		t.Errorf("it should set the beginning of the day: %v", sample.Today)
+	}
+	if !sample.Tomorrow.Equal(time.Date(2024, 8, 14, 0, 0, 0, 0, loc)) {
+		t.Errorf("it should set a time relative to the beginning of the day: %v", sample.Tomorrow)
+	}
+	if sample.Epoch.Unix() != 1700000000 {
+		t.Errorf("it should set a time from epoch seconds: %v", sample.Epoch)
+	}
+	if !sample.RFC3339.Equal(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)) {
+		t.Errorf("it should set a time in RFC 3339: %v", sample.RFC3339)
+	}
+	if !sample.Layout.Equal(time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("it should set a time in the given layout: %v", sample.Layout)
+	}
+	if sample.Ptr == nil || !sample.Ptr.Equal(time.Date(2024, 1, 2, 15, 4, 0, 0, time.UTC)) {
+		t.Errorf("it should set a time pointer in the given layout: %v", sample.Ptr)
+	}
+	if !sample.NoTag.IsZero() {
+		t.Errorf("it should not set a time without a tag")
+	}
+	if sample.NonInitial.Unix() != 1 {
+		t.Errorf("it should not override a non-initial time")
+	}
+
+	invalids := []interface{}{
+		&struct {
+			T time.Time `default:"now+1x"`
+		}{},
+		&struct {
+			T time.Time `default:"yesterday"`
+		}{},
+		&struct {
+			T time.Time `default:"2024-01-02"`
+		}{},
+		&struct {
+			T time.Time `default:"2024/01/02" layout:"2006-01-02"`
+		}{},
+	}
+	for _, ptr := range invalids {
+		if err := SetWithOptions(ptr, clock); !errors.Is(err, ErrParse) {
+			t.Errorf("it should return an error for an invalid time: %v", err)
+		}
+	}
+}
+
+type Timeout int64
+
+func TestInt64Duration(t *testing.T) {
+	type sample struct {
+		Duration time.Duration `default:"1h"`
+		Int64    int64         `default:"1h"`
+		MyInt64  MyInt64       `default:"1h"`
+		Plain    int64         `default:"3600"`
+	}
+
+	s := &sample{}
+	if err := Set(s); err != nil {
+		t.Fatalf("it should not return an error: %v", err)
+	}
+	if s.Duration != time.Hour {
+		t.Errorf("it should parse a duration for time.Duration")
+	}
+	if s.Int64 != 0 || s.MyInt64 != 0 {
+		t.Errorf("it should not parse a duration for int64: %d, %d", s.Int64, s.MyInt64)
+	}
+	if s.Plain != 3600 {
+		t.Errorf("it should parse an integer for int64")
+	}
+	if err := SetWithOptions(&sample{}, Strict()); !errors.Is(err, ErrParse) {
+		t.Errorf("it should return an error for a duration on int64 in the strict mode: %v", err)
+	}
+
+	s = &sample{}
+	if err := SetWithOptions(s, Int64AsDuration()); err != nil {
+		t.Fatalf("it should not return an error: %v", err)
+	}
+	if s.Int64 != int64(time.Hour) || s.MyInt64 != MyInt64(time.Hour) || s.Plain != 3600 {
+		t.Errorf("it should parse a duration for any int64 with Int64AsDuration")
+	}
+
+	t.Run("registered parser", func(t *testing.T) {
+		RegisterParser(Timeout(0), func(s string) (interface{}, error) {
+			return time.ParseDuration(s)
+		})
+
+		s := &struct {
+			Timeout    Timeout  `default:"30s"`
+			TimeoutPtr *Timeout `default:"1m"`
+			Invalid    Timeout  `default:"30"`
+		}{}
+		if err := Set(s); err != nil {
+			t.Fatalf("it should not return an error: %v", err)
+		}
+		if s.Timeout != Timeout(30*time.Second) {
+			t.Errorf("it should parse with a registered parser: %d", s.Timeout)
+		}
+		if s.TimeoutPtr == nil || *s.TimeoutPtr != Timeout(time.Minute) {
+			t.Errorf("it should parse a pointer with a registered parser")
+		}
+		if s.Invalid != 0 {
+			t.Errorf("it should ignore a failure of a registered parser without the strict mode")
+		}
+		if err := SetWithOptions(&struct {
+			Invalid Timeout `default:"30"`
+		}{}, Strict()); !errors.Is(err, ErrParse) {
+			t.Errorf("it should return an error of a registered parser in the strict mode: %v", err)
+		}
+	})
+}
+
+func TestTagName(t *testing.T) {
+	type sample struct {
+		Port   int    `default:"80" envDefault:"8080"`
+		Host   string `envDefault:"localhost"`
+		Ignore string `default:"ignored" envDefault:"-"`
+		Nested struct {
+			Name string `default:"name" envDefault:"env-name"`
+		}
+	}
+
+	s := &sample{}
+	if err := SetWithOptions(s, TagName("envDefault")); err != nil {
+		t.Fatalf("it should not return an error: %v", err)
+	}
+	if s.Port != 8080 || s.Host != "localhost" || s.Ignore != "" || s.Nested.Name != "env-name" {
+		t.Errorf("it should read default values from the given tag: %+v", s)
+	}
+
+	s = &sample{}
+	if err := Set(s); err != nil {
+		t.Fatalf("it should not return an error: %v", err)
+	}
+	if s.Port != 80 || s.Host != "" || s.Ignore != "ignored" || s.Nested.Name != "name" {
+		t.Errorf("it should read default values from the default tag: %+v", s)
+	}
+}
+
+func TestDefaulter(t *testing.T) {
+	d := NewDefaulter(TagName("def"), Strict())
+
+	type sample struct {
+		Int    int    `def:"1" default:"2"`
+		String string `def:"foo"`
+	}
+
+	for i := 0; i < 2; i++ {
+		s := &sample{}
+		if err := d.Set(s); err != nil {
+			t.Fatalf("it should not return an error: %v", err)
+		}
+		if s.Int != 1 || s.String != "foo" {
+			t.Errorf("it should set default values with its options: %+v", s)
+		}
+	}
+
+	if err := d.Set(&struct {
+		Int int `def:"x"`
+	}{}); !errors.Is(err, ErrParse) {
+		t.Errorf("it should apply all of its options: %v", err)
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("MustSet should panic on an error")
+			}
+		}()
+		d.MustSet(&struct {
+			Int int `def:"x"`
+		}{})
+	}()
+}
+
+func TestNew(t *testing.T) {
+	s, err := New[Struct]()
+	if err != nil {
+		t.Fatalf("it should not return an error: %v", err)
+	}
+	if s.WithDefault != "foo" || s.Bar != 456 || s.Int != 1 {
+		t.Errorf("it should return a pointer initialized with default values: %+v", s)
+	}
+
+	if _, err := New[int](); !errors.Is(err, ErrInvalidType) {
+		t.Errorf("it should return an error for a non-struct type: %v", err)
+	}
+	if _, err := New[struct {
+		I int `default:"x"`
+	}](Strict()); !errors.Is(err, ErrParse) {
+		t.Errorf("it should apply options: %v", err)
+	}
+
+	if s := MustNew[Struct](); s.WithDefault != "foo" {
+		t.Errorf("it should return a pointer initialized with default values: %+v", s)
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("MustNew should panic on an error")
+			}
+		}()
+		MustNew[int]()
+	}()
+
+	v, err := Value[Struct]()
+	if err != nil {
+		t.Fatalf("it should not return an error: %v", err)
+	}
+	if v.WithDefault != "foo" || v.Bar != 456 {
+		t.Errorf("it should return a value initialized with default values: %+v", v)
+	}
+}
+
+func TestPlanCache(t *testing.T) {
+	type sample struct {
+		Slice  []string          `default:"[\"a\", \"b\"]"`
+		Map    map[string][]int  `default:"{\"a\": [1]}"`
+		Struct Struct            `default:"{\"Foo\": 1}"`
+		Ptr    *Struct           `default:"{\"Foo\": 1}"`
+		Array  [1]map[string]int `default:"[{\"a\": 1}]"`
+	}
+
+	s1 := &sample{}
+	MustSet(s1)
+	s1.Slice[0] = "changed"
+	s1.Map["a"][0] = 2
+	s1.Map["b"] = nil
+	s1.Ptr.Foo = 2
+	s1.Array[0]["a"] = 2
+
+	s2 := &sample{}
+	MustSet(s2)
+	if !reflect.DeepEqual(s2.Slice, []string{"a", "b"}) ||
+		!reflect.DeepEqual(s2.Map, map[string][]int{"a": {1}}) ||
+		s2.Ptr.Foo != 1 || s2.Array[0]["a"] != 1 {
+		t.Errorf("it should not share cached values between structs: %+v", s2)
+	}
+
+	type unmarshaled struct {
+		Rules []allowList `default:"[[\"a\"]]"`
+		Nums  []*big.Int  `default:"[100]"`
+	}
+	u1 := &unmarshaled{}
+	MustSet(u1)
+	u1.Rules[0].m["b"] = true
+	u1.Nums[0].SetInt64(7)
+
+	u2 := &unmarshaled{}
+	MustSet(u2)
+	if !reflect.DeepEqual(u2.Rules[0].m, map[string]bool{"a": true}) || u2.Nums[0].Int64() != 100 {
+		t.Errorf("it should not share values decoded by UnmarshalJSON between structs: %v %v", u2.Rules[0].m, u2.Nums)
+	}
+
+	t.Run("concurrent", func(t *testing.T) {
+		type concurrent struct {
+			Int   int      `default:"1"`
+			Slice []Struct `default:"[{}]"`
+		}
+		done := make(chan *concurrent)
+		for i := 0; i < 8; i++ {
+			go func() {
+				c := &concurrent{}
+				MustSet(c)
+				done <- c
+			}()
+		}
+		for i := 0; i < 8; i++ {
+			if c := <-done; c.Int != 1 || len(c.Slice) != 1 || c.Slice[0].WithDefault != "foo" {
+				t.Errorf("it should set default values concurrently: %+v", c)
+			}
+		}
+	})
+}
+
+// allowList fills an unexported map when it is decoded.
+type allowList struct {
+	m map[string]bool
+}
+
+func (a *allowList) UnmarshalJSON(b []byte) error {
+	var names []string
+	if err := json.Unmarshal(b, &names); err != nil {
+		return err
+	}
+	a.m = make(map[string]bool, len(names))
+	for _, name := range names {
+		a.m[name] = true
+	}
+	return nil
+}
+
+// generated stands for a type with a SetDefaults generated by defaults-gen,
+// which is told apart from the walk by setting a different value.
+type generated struct {
+	Value string `default:"walked"`
+}
+
+func (g *generated) SetDefaults() {
+	if g.Value == "" {
+		g.Value = "generated"
+	}
+}
+
+func (g *generated) GeneratedDefaults() string { return "default" }
+
+func TestGeneratedSetter(t *testing.T) {
+	type parent struct {
+		Child    generated
+		ChildPtr *generated `default:"{}"`
+	}
+
+	cases := []struct {
+		name string
+		opts []Option
+		want string
+	}{
+		{"no options", nil, "generated"},
+		{"IgnoreGenerated", []Option{IgnoreGenerated()}, "walked"},
+		{"TagName", []Option{TagName("default")}, "generated"},
+		{"Clock", []Option{Clock(time.Now)}, "walked"},
+		{"Int64AsDuration", []Option{Int64AsDuration()}, "walked"},
+		{"Strict", []Option{Strict()}, "walked"},
+		{"AllErrors", []Option{AllErrors()}, "walked"},
+	}
+
+	for _, c := range cases {
+		p := &parent{}
+		if err := SetWithOptions(p, c.opts...); err != nil {
+			t.Fatalf("%s: it should not return an error: %v", c.name, err)
+		}
+		if p.Child.Value != c.want || p.ChildPtr.Value != c.want {
+			t.Errorf("%s: it should set %q, got %q and %q", c.name, c.want, p.Child.Value, p.ChildPtr.Value)
+		}
+	}
+}
+
+func TestEnv(t *testing.T) {
+	type db struct {
+		Host string `default:"localhost" env:"HOST"`
+		Port int    `default:"5432" env:"PORT"`
+	}
+	type config struct {
+		Port    int           `default:"8080" env:"PORT"`
+		Name    string        `default:"app" env:"NAME"`
+		Timeout time.Duration `default:"1s" env:"TIMEOUT"`
+		Tags    []string      `env:"TAGS"`
+		Ptr     *int          `env:"PTR"`
+		DB      db            `envPrefix:"DB_"`
+		Replica *db           `envPrefix:"REPLICA_"`
+		Shards  []db          `default:"[{}, {}]" envPrefix:"SHARD_"`
+	}
+
+	env := map[string]string{
+		"APP_SHARD_HOST": "shard.local",
+		"APP_PORT":       "9090",
+		"APP_NAME":       "env",
+		"APP_TIMEOUT":    "2m",
+		"APP_TAGS":       `["a", "b"]`,
+		"APP_PTR":        "3",
+		"APP_DB_HOST":    "db.local",
+		"PORT":           "1",
+	}
+	lookup := func(key string) (string, bool) {
+		val, ok := env[key]
+		return val, ok
+	}
+
+	c := &config{Name: "set"}
+	if err := SetWithOptions(c, Env("APP_"), EnvLookup(lookup)); err != nil {
+		t.Fatalf("it should not return an error: %v", err)
+	}
+	if c.Port != 9090 || c.Name != "env" || c.Timeout != 2*time.Minute {
+		t.Errorf("it should override fields by environment variables: %+v", c)
+	}
+	if !reflect.DeepEqual(c.Tags, []string{"a", "b"}) || c.Ptr == nil || *c.Ptr != 3 {
+		t.Errorf("it should parse environment variables like default values: %v, %v", c.Tags, c.Ptr)
+	}
+	if c.DB.Host != "db.local" || c.DB.Port != 5432 {
+		t.Errorf("it should prefix environment variables of nested fields: %+v", c.DB)
+	}
+	if c.Replica != nil {
+		t.Errorf("it should not allocate a nil pointer without a default value: %+v", c.Replica)
+	}
+	if c.Shards[0].Host != "localhost" || c.Shards[1].Host != "localhost" {
+		t.Errorf("it should not look up fields in elements of slices: %+v", c.Shards)
+	}
+
+	c = &config{}
+	if err := SetWithOptions(c, EnvLookup(lookup)); err != nil {
+		t.Fatalf("it should not return an error: %v", err)
+	}
+	if c.Port != 8080 || c.DB.Host != "localhost" {
+		t.Errorf("it should ignore environment variables without Env: %+v", c)
+	}
+
+	env["APP_DB_PORT"] = "x"
+	err := SetWithOptions(&config{}, Env("APP_"), EnvLookup(lookup))
+	var fieldErr *FieldError
+	if !errors.As(err, &fieldErr) || fieldErr.Path != "DB.Port" || !errors.Is(err, ErrParse) {
+		t.Errorf("it should fail to parse an environment variable without Strict: %v", err)
+	}
+
+	c = &config{Port: 1}
+	err = SetWithOptions(c, Env("APP_"), EnvLookup(lookup), AllErrors())
+	if !errors.Is(err, ErrParse) || c.DB.Port != 5432 {
+		t.Errorf("it should keep the default value of a field failing to parse: %v, %+v", err, c.DB)
+	}
+
+	type zero struct {
+		Debug bool `default:"true" env:"DEBUG"`
+		Port  int  `default:"8080" env:"PORT"`
+	}
+	z := &zero{}
+	zeroEnv := EnvLookup(func(key string) (string, bool) {
+		return map[string]string{"DEBUG": "false", "PORT": "0"}[key], true
+	})
+	if err := SetWithOptions(z, Env(""), zeroEnv); err != nil || z.Debug || z.Port != 0 {
+		t.Errorf("it should not apply default values over zero values of environment variables: %v, %+v", err, z)
+	}
+
+	env = map[string]string{"APP_REPLICA_HOST": "replica.local"}
+	c = &config{Replica: &db{}}
+	if err := SetWithOptions(c, Env("APP_"), EnvLookup(lookup)); err != nil || c.Replica.Host != "replica.local" {
+		t.Errorf("it should apply the prefix of a pointer field once: %v, %+v", err, c.Replica)
+	}
+	c = &config{Replica: &db{}}
+	if _, err := Load(c, EnvSource("APP_", lookup)); err != nil || c.Replica.Host != "replica.local" {
+		t.Errorf("it should name variables like EnvSource: %v, %+v", err, c.Replica)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	type db struct {
+		Host string `default:"localhost" json:"host" env:"HOST"`
+		Port int    `default:"5432" json:"port" env:"PORT"`
+	}
+	type config struct {
+		Name    string        `default:"app" json:"name"`
+		Port    int           `default:"8080" env:"PORT" flag:"port"`
+		Timeout time.Duration `default:"1s" json:"timeout"`
+		Tags    []string      `json:"tags"`
+		DB      db            `json:"db" envPrefix:"DB_"`
+		Replica *db           `json:"replica" envPrefix:"REPLICA_"`
+		Debug   bool          `flag:"debug"`
+		Secret  string        `json:"-"`
+	}
+
+	file, err := JSONSource("config.json", []byte(`{
+		"-": "leak",
+		"name": "file",
+		"port": 1,
+		"timeout": "5s",
+		"tags": ["a"],
+		"db": {"host": "file.local"},
+		"replica": {"port": 6543}
+	}`))
+	if err != nil {
+		t.Fatalf("it should not return an error: %v", err)
+	}
+	env := EnvSource("APP_", func(key string) (string, bool) {
+		val, ok := map[string]string{"APP_PORT": "9090", "APP_DB_HOST": "env.local"}[key]
+		return val, ok
+	})
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.Int("port", 0, "")
+	fs.Bool("debug", false, "")
+	if err := fs.Parse([]string{"-port", "7070"}); err != nil {
+		t.Fatal(err)
+	}
+	memory := MapSource("memory", map[string]string{"Debug": "true", "DB.Port": "5433"})
+
+	c := &config{}
+	origins, err := Load(c, file, env, FlagSource(fs), memory)
+	if err != nil {
+		t.Fatalf("it should not return an error: %v", err)
+	}
+	want := &config{
+		Name:    "file",
+		Port:    7070,
+		Timeout: 5 * time.Second,
+		Tags:    []string{"a"},
+		DB:      db{Host: "env.local", Port: 5433},
+		Replica: &db{Host: "localhost", Port: 6543},
+		Debug:   true,
+	}
+	if !reflect.DeepEqual(c, want) {
+		t.Errorf("it should override default values by sources in order\ngot:  %+v\nwant: %+v", c, want)
+	}
+	wantOrigins := Origins{
+		"Name":         "config.json",
+		"Port":         "flag",
+		"Timeout":      "config.json",
+		"Tags":         "config.json",
+		"DB.Host":      "env",
+		"DB.Port":      "memory",
+		"Replica.Host": DefaultOrigin,
+		"Replica.Port": "config.json",
+		"Debug":        "memory",
+	}
+	if !reflect.DeepEqual(origins, wantOrigins) {
+		t.Errorf("it should record the origins of values\ngot:  %v\nwant: %v", origins, wantOrigins)
+	}
+
+	origins, err = Load(&config{Name: "set"})
+	if err != nil {
+		t.Fatalf("it should not return an error: %v", err)
+	}
+	if _, ok := origins["Name"]; ok || origins["Port"] != DefaultOrigin {
+		t.Errorf("it should record only values set from default values: %v", origins)
+	}
+
+	_, err = Load(&config{}, MapSource("memory", map[string]string{"Port": "x"}))
+	var fieldErr *FieldError
+	if !errors.As(err, &fieldErr) || fieldErr.Path != "Port" || !errors.Is(err, ErrParse) {
+		t.Errorf("it should fail to parse a value of a source: %v", err)
+	}
+
+	zeros, err := JSONSource("config.json", []byte(`{"port": 0, "db": {"port": 0}}`))
+	if err != nil {
+		t.Fatalf("it should not return an error: %v", err)
+	}
+	fs = flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.Bool("debug", true, "")
+	if err := fs.Parse([]string{"-debug=false"}); err != nil {
+		t.Fatal(err)
+	}
+	c = &config{Debug: true}
+	origins, err = Load(c, MapSource("memory", map[string]string{"Port": "1"}), zeros, FlagSource(fs))
+	if err != nil {
+		t.Fatalf("it should not return an error: %v", err)
+	}
+	if c.Port != 0 || c.DB.Port != 0 || c.Debug {
+		t.Errorf("it should override fields by zero values of sources: %+v", c)
+	}
+	if origins["Port"] != "config.json" || origins["DB.Port"] != "config.json" || origins["Debug"] != "flag" {
+		t.Errorf("it should record the origins of zero values: %v", origins)
+	}
+
+	l := &loadCounter{}
+	if _, err := Load(l, MapSource("a", map[string]string{"A": "1"}), MapSource("b", map[string]string{"A": "2"})); err != nil {
+		t.Fatalf("it should not return an error: %v", err)
+	}
+	if !reflect.DeepEqual(l, &loadCounter{A: 2, L: []string{"x"}}) {
+		t.Errorf("it should call a setter once after overriding fields: %+v", l)
+	}
+}
+
+type loadCounter struct {
+	A int `default:"0"`
+	L []string
+}
+
+func (l *loadCounter) SetDefaults() {
+	l.L = append(l.L, "x")
+}
+
+type retryPolicy struct {
+	Retries int `default:"3"`
+	Backoff time.Duration
+}
+
+func (p *retryPolicy) SetDefaults() {
+	if p.Backoff == 0 {
+		p.Backoff = time.Duration(p.Retries) * time.Second
+	}
+}
+
+func TestSetWithReport(t *testing.T) {
+	type sample struct {
+		Name    string        `default:"app"`
+		Port    int           `default:"8080" env:"PORT"`
+		Timeout time.Duration `default:"30s"`
+		Retry   retryPolicy
+		NoTag   int
+		Debug   bool `default:"false"`
+		Zero    int  `default:"0"`
+		Invalid int  `default:"x"`
+	}
+
+	s := &sample{Name: "set"}
+	report, err := SetWithReport(s, Env(""), EnvLookup(func(key string) (string, bool) {
+		return "9090", key == "PORT"
+	}))
+	if err != nil {
+		t.Fatalf("it should not return an error: %v", err)
+	}
+	want := Report{
+		{Path: "Name", Origin: PreservedOrigin, Tag: "app", Value: "set"},
+		{Path: "Port", Origin: EnvOrigin, Tag: "8080", Value: 9090},
+		{Path: "Timeout", Origin: DefaultOrigin, Tag: "30s", Value: 30 * time.Second},
+		{Path: "Retry.Retries", Origin: DefaultOrigin, Tag: "3", Value: 3},
+		{Path: "Retry.Backoff", Origin: SetterOrigin, Tag: "", Value: 3 * time.Second},
+		{Path: "Debug", Origin: DefaultOrigin, Tag: "false", Value: false},
+		{Path: "Zero", Origin: DefaultOrigin, Tag: "0", Value: 0},
+	}
+	if !reflect.DeepEqual(report, want) {
+		t.Errorf("it should report where the values came from\ngot:  %+v\nwant: %+v", report, want)
+	}
+	origins, err := Load(&sample{})
+	if err != nil || origins["Debug"] != DefaultOrigin || origins["Zero"] != DefaultOrigin {
+		t.Errorf("it should record zero default values in origins: %v, %v", err, origins)
+	}
+	if _, ok := origins["Invalid"]; ok {
+		t.Errorf("it should not record an invalid default value ignored: %v", origins)
+	}
+
+	type withFunc struct {
+		Fn    func()
+		Retry retryPolicy
+	}
+	report, err = SetWithReport(&withFunc{Fn: func() {}, Retry: retryPolicy{Retries: 1, Backoff: time.Second}})
+	if err != nil || len(report) != 1 || report[0].Path != "Retry.Retries" {
+		t.Errorf("it should not report unchanged funcs as set by setters: %v, %+v", err, report)
+	}
+
+	report, err = SetWithReport(&struct {
+		Port int `default:"x"`
+	}{}, Strict())
+	if !errors.Is(err, ErrParse) || len(report) != 0 {
+		t.Errorf("it should return an error with the fields reported so far: %v, %+v", err, report)
+	}
+}
+
+// hiddenCounter counts the calls of its setter in an unexported map.
+type hiddenCounter struct {
+	counts map[string]int
+}
+
+func (h *hiddenCounter) SetDefaults() {
+	if h.counts != nil {
+		h.counts["SetDefaults"]++
+	}
+}
+
+func TestPlan(t *testing.T) {
+	type server struct {
+		Host string `default:"localhost"`
+	}
+	type sample struct {
+		Name    string         `default:"app"`
+		Port    int            `default:"8080"`
+		Tags    []string       `default:"[\"a\"]"`
+		Limits  map[string]int `default:"{\"cpu\": 2}"`
+		Servers []server
+		Retry   *retryPolicy `default:"{}"`
+	}
+	newSample := func() *sample {
+		return &sample{
+			Name:    "set",
+			Limits:  map[string]int{"mem": 1},
+			Servers: []server{{}, {Host: "example.com"}},
+		}
+	}
+
+	s := newSample()
+	changes, err := Plan(s)
+	if err != nil {
+		t.Fatalf("it should not return an error: %v", err)
+	}
+	want := []Change{
+		{Path: "Port", Old: 0, New: 8080},
+		{Path: "Tags", Old: []string(nil), New: []string{"a"}},
+		{Path: "Servers[0].Host", Old: "", New: "localhost"},
+		{Path: "Retry", Old: (*retryPolicy)(nil), New: &retryPolicy{Retries: 3, Backoff: 3 * time.Second}},
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("it should return the changes Set would make\ngot:  %+v\nwant: %+v", changes, want)
+	}
+	if !reflect.DeepEqual(s, newSample()) {
+		t.Errorf("it should not modify the struct: %+v", s)
+	}
+
+	type withHidden struct {
+		Hidden hiddenCounter
+		Fn     func()
+	}
+	h := &withHidden{Hidden: hiddenCounter{counts: map[string]int{}}, Fn: func() {}}
+	changes, err = Plan(h)
+	if err != nil {
+		t.Fatalf("it should not return an error: %v", err)
+	}
+	if len(h.Hidden.counts) != 0 {
+		t.Errorf("it should not modify unexported fields by setters: %v", h.Hidden.counts)
+	}
+	for _, c := range changes {
+		if c.Path == "Fn" {
+			t.Errorf("it should not report changes of unchanged funcs: %+v", c)
+		}
+	}
+
+	if _, err := Plan(sample{}); err != ErrInvalidType {
+		t.Errorf("it should return ErrInvalidType for a non-pointer: %v", err)
+	}
+}
+
+func TestReset(t *testing.T) {
+	type pool struct {
+		Size int `default:"10"`
+		Idle int
+	}
+	type db struct {
+		Host string `default:"localhost"`
+		Pool *pool
+	}
+	type sample struct {
+		Name string   `default:"app"`
+		Tags []string `default:"[\"a\"]"`
+		DB   db
+		Note string
+	}
+
+	s := &sample{
+		Name: "changed",
+		Tags: []string{"x", "y"},
+		DB:   db{Host: "example.com", Pool: &pool{Size: 1, Idle: 2}},
+		Note: "kept",
+	}
+	if err := Reset(s); err != nil {
+		t.Fatalf("it should not return an error: %v", err)
+	}
+	want := &sample{
+		Name: "app",
+		Tags: []string{"a"},
+		DB:   db{Host: "localhost", Pool: &pool{Size: 10, Idle: 2}},
+		Note: "kept",
+	}
+	if !reflect.DeepEqual(s, want) {
+		t.Errorf("it should reset fields with default values\ngot:  %+v\nwant: %+v", s, want)
+	}
+
+	s = &sample{Name: "changed", DB: db{Host: "example.com"}}
+	if err := ResetField(s, "DB.Pool.Size"); err != nil {
+		t.Fatalf("it should not return an error: %v", err)
+	}
+	if s.DB.Pool == nil || s.DB.Pool.Size != 10 || s.DB.Host != "example.com" || s.Name != "changed" {
+		t.Errorf("it should reset only the field named by the path: %+v", s)
+	}
+	if err := ResetField(s, "Name"); err != nil || s.Name != "app" {
+		t.Errorf("it should reset a top-level field: %v, %+v", err, s)
+	}
+
+	for _, path := range []string{"", "DB.Port", "Name.Size", "DB.Pool.Size.X"} {
+		if err := ResetField(s, path); !errors.Is(err, ErrFieldNotFound) {
+			t.Errorf("it should return ErrFieldNotFound for %q: %v", path, err)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	type server struct {
+		Host string `default:"localhost"`
+		Port int    `default:"80"`
+	}
+	type sample struct {
+		Name    string            `default:"app"`
+		Servers []server          `default:"[{}, {}]"`
+		Labels  map[string]string `default:"{\"env\": \"dev\"}"`
+		Primary *server           `default:"{}"`
+		Note    string
+		Created time.Time  `default:"now"`
+		Expires *time.Time `default:"today+24h"`
+	}
+
+	s := &sample{}
+	MustSet(s)
+	time.Sleep(time.Millisecond)
+	if !IsDefault(s) || len(Diff(s)) != 0 {
+		t.Errorf("it should have no differences after Set: %+v", Diff(s))
+	}
+
+	s.Name = "custom"
+	s.Servers[1].Port = 8080
+	s.Labels["env"] = "prod"
+	s.Labels["team"] = "core"
+	s.Primary.Host = "example.com"
+	s.Note = "note"
+	want := []Change{
+		{Path: "Name", Old: "app", New: "custom"},
+		{Path: "Servers[1].Port", Old: 80, New: 8080},
+		{Path: "Labels[env]", Old: "dev", New: "prod"},
+		{Path: "Labels[team]", Old: nil, New: "core"},
+		{Path: "Primary.Host", Old: "localhost", New: "example.com"},
+		{Path: "Note", Old: "", New: "note"},
+	}
+	if changes := Diff(s); !reflect.DeepEqual(changes, want) {
+		t.Errorf("it should report the differences from default values\ngot:  %+v\nwant: %+v", changes, want)
+	}
+	if IsDefault(s) {
+		t.Errorf("it should not be default")
+	}
+
+	s.Servers = s.Servers[:1]
+	s.Primary = nil
+	want = []Change{
+		{Path: "Name", Old: "app", New: "custom"},
+		{Path: "Servers", Old: []server{{"localhost", 80}, {"localhost", 80}}, New: []server{{"localhost", 80}}},
+		{Path: "Labels[env]", Old: "dev", New: "prod"},
+		{Path: "Labels[team]", Old: nil, New: "core"},
+		{Path: "Primary", Old: &server{"localhost", 80}, New: (*server)(nil)},
+		{Path: "Note", Old: "", New: "note"},
+	}
+	if changes := Diff(s); !reflect.DeepEqual(changes, want) {
+		t.Errorf("it should report a slice of a different length and a nil pointer as a whole\ngot:  %+v\nwant: %+v", changes, want)
+	}
+
+	if IsDefault(sample{}) || Diff(sample{}) != nil {
+		t.Errorf("it should not compare a non-pointer")
+	}
+}
+
+func TestPrune(t *testing.T) {
+	type db struct {
+		Host string `default:"localhost" json:"host"`
+		User string `json:"user"`
+	}
+	type sample struct {
+		Name string    `default:"app" json:"name"`
+		Port int       `default:"8080" json:"port"`
+		DB   db        `json:"db"`
+		Tags []string  `default:"[\"a\"]" json:"tags"`
+		Pool *db       `default:"{}" json:"pool"`
+		Day  time.Time `default:"today" json:"day"`
+	}
+	y, m, d := time.Now().Date()
+	day := time.Date(y, m, d, 0, 0, 0, 0, time.Local)
+	dayJSON, _ := json.Marshal(day)
+	newSample := func() *sample {
+		s := &sample{Day: day}
+		MustSet(s)
+		s.Port = 9090
+		s.DB.User = "admin"
+		return s
+	}
+
+	data, err := MarshalJSONMinimal(newSample())
+	if err != nil {
+		t.Fatalf("it should not return an error: %v", err)
+	}
+	want := `{"port":9090,"db":{"user":"admin"},"day":` + string(dayJSON) + `}`
+	if string(data) != want {
+		t.Errorf("it should encode only values differing from default values: %s, want %s", data, want)
+	}
+	if data, _ := MarshalJSONMinimal(*newSample()); string(data) != want {
+		t.Errorf("it should accept a struct: %s", data)
+	}
+	if _, err := MarshalJSONMinimal(1); err != ErrInvalidType {
+		t.Errorf("it should return ErrInvalidType for a non-struct: %v", err)
+	}
+
+	s := newSample()
+	if err := Prune(s); err != nil {
+		t.Fatalf("it should not return an error: %v", err)
+	}
+	wantPruned := &sample{Port: 9090, DB: db{User: "admin"}, Day: day}
+	if !reflect.DeepEqual(s, wantPruned) {
+		t.Errorf("it should zero fields holding default values but relative times\ngot:  %+v\nwant: %+v", s, wantPruned)
+	}
+	MustSet(s)
+	if !reflect.DeepEqual(s, newSample()) {
+		t.Errorf("it should be restored by Set: %+v", s)
+	}
+}
+
+func TestUnmarshalJSON(t *testing.T) {
+	type server struct {
+		Host  string      `default:"localhost" json:"host"`
+		Port  int         `default:"8080" json:"port"`
+		Retry retryPolicy `json:"retry"`
+	}
+	type sample struct {
+		Name    string             `default:"app" json:"name"`
+		Servers []server           `json:"servers"`
+		Backups map[string]*server `json:"backups"`
+		Primary *server            `json:"primary"`
+		Count   int                `default:"3" json:"count,string"`
+	}
+
+	data := `{
+		"name": "svc",
+		"servers": [{"host": "a"}, {"port": 0}],
+		"backups": {"b": {"port": 9090}, "c": null},
+		"primary": {"retry": {"Retries": 5}},
+		"count": "7"
+	}`
+	s := &sample{}
+	if err := UnmarshalJSON([]byte(data), s); err != nil {
+		t.Fatalf("it should not return an error: %v", err)
+	}
+	want := &sample{
+		Name: "svc",
+		Servers: []server{
+			{Host: "a", Port: 8080, Retry: retryPolicy{Retries: 3, Backoff: 3 * time.Second}},
+			{Host: "localhost", Port: 0, Retry: retryPolicy{Retries: 3, Backoff: 3 * time.Second}},
+		},
+		Backups: map[string]*server{
+			"b": {Host: "localhost", Port: 9090, Retry: retryPolicy{Retries: 3, Backoff: 3 * time.Second}},
+			"c": nil,
+		},
+		Primary: &server{Host: "localhost", Port: 8080, Retry: retryPolicy{Retries: 5, Backoff: 5 * time.Second}},
+		Count:   7,
+	}
+	if !reflect.DeepEqual(s, want) {
+		t.Errorf("it should set default values to values created by decoding\ngot:  %+v\nwant: %+v", s, want)
+	}
+
+	var servers []server
+	if err := UnmarshalJSON([]byte(`[{"host": "x"}]`), &servers); err != nil {
+		t.Fatalf("it should not return an error: %v", err)
+	}
+	if len(servers) != 1 || servers[0].Host != "x" || servers[0].Port != 8080 {
+		t.Errorf("it should decode into a slice of structs: %+v", servers)
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if err := UnmarshalJSON([]byte(`{"port": "x"}`), &server{}); !errors.As(err, &typeErr) {
+		t.Errorf("it should return an error of encoding/json: %v", err)
+	}
+	if err := UnmarshalJSON([]byte(`{`), &server{}); err == nil {
+		t.Errorf("it should return an error for invalid JSON")
+	}
+	if err := UnmarshalJSON([]byte(`{}`), server{}); err == nil {
+		t.Errorf("it should return an error for a non-pointer")
+	}
+
+	dec := NewDecoder(strings.NewReader(`{"host": "a"} {"port": 1}`))
+	var got []server
+	for dec.More() {
+		var srv server
+		if err := dec.Decode(&srv); err != nil {
+			t.Fatalf("it should not return an error: %v", err)
+		}
+		got = append(got, srv)
+	}
+	if len(got) != 2 || got[0].Port != 8080 || got[1].Host != "localhost" || got[1].Port != 1 {
+		t.Errorf("it should decode a stream of values: %+v", got)
+	}
+
+	dec = NewDecoder(strings.NewReader(`{"hots": "a"}`))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&server{}); err == nil {
+		t.Errorf("it should return an error for an unknown field")
+	}
+}
+
+// jsonEmbedded is embedded by jsonPlain to decode promoted fields.
+type jsonEmbedded struct {
+	E []int
+}
+
+// jsonInner is a struct nested in jsonPlain.
+type jsonInner struct {
+	X string
+	Y []int
+}
+
+// jsonPlain is a struct without default values, which UnmarshalJSON decodes like json.Unmarshal.
+type jsonPlain struct {
+	Name  string `json:"name"`
+	N     int
+	F     float32
+	Q     int  `json:",string"`
+	QP    *int `json:",string"`
+	S     []int
+	A     [2]bool
+	M     map[int]string
+	Inner *jsonInner
+	Anon  struct {
+		X string
+	}
+	List []jsonInner
+	Any  interface{}
+	Time time.Time
+	jsonEmbedded
+}
+
+func TestUnmarshalJSONLikeEncoding(t *testing.T) {
+	plain := func() interface{} { return &jsonPlain{} }
+	cases := []struct {
+		in  string
+		new func() interface{}
+	}{
+		{`{"name": "a", "Name": "b", "NAME": "c"}`, plain},
+		{`{"N": "bad", "name": "a", "S": [1, "x", 3, "y"], "F": 1e100}`, plain},
+		{`{"S": "x", "A": [true, 1, false], "N": 2}`, plain},
+		{`{"QP": "3", "Q": "7", "Inner": {"X": 1, "Y": [true]}, "E": [1, {}]}`, plain},
+		{`{"Anon": {"X": 1}, "List": [{}, {"Y": ["a"]}], "N": 1}`, plain},
+		{`{"Q": 5, "N": 1}`, plain},
+		{`{"Q": "abc", "N": 1}`, plain},
+		{`{"M": {"1": "a", "x": "b", "2": 3}, "N": 1}`, plain},
+		{`{"Inner": [], "Any": {"a": [1]}, "Time": "bad", "N": 1}`, plain},
+		{`{"Inner": null, "M": null, "S": null, "Any": null, "N": null}`, plain},
+		{` [1, 2] `, plain},
+		{`[{}, {"X": 1}]`, func() interface{} { return &[]jsonInner{} }},
+		{`{"a": {"X": 1}}`, func() interface{} { return &map[string]*jsonInner{} }},
+		{`{"X": 1}`, func() interface{} { return new(*jsonInner) }},
+	}
+	for _, c := range cases {
+		want, got := c.new(), c.new()
+		wantErr := json.Unmarshal([]byte(c.in), want)
+		gotErr := UnmarshalJSON([]byte(c.in), got)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: it should decode like encoding/json\ngot:  %+v\nwant: %+v", c.in, got, want)
+		}
+		if fmt.Sprint(gotErr) != fmt.Sprint(wantErr) || reflect.TypeOf(gotErr) != reflect.TypeOf(wantErr) {
+			t.Errorf("%s: it should return the error of encoding/json\ngot:  %#v\nwant: %#v", c.in, gotErr, wantErr)
+		}
+		var gotType, wantType *json.UnmarshalTypeError
+		if errors.As(gotErr, &gotType) && errors.As(wantErr, &wantType) && gotType.Offset != wantType.Offset {
+			t.Errorf("%s: it should return the offset of encoding/json: %d, want %d", c.in, gotType.Offset, wantType.Offset)
+		}
+	}
+}
+
+func TestRequired(t *testing.T) {
+	type db struct {
+		DSN  string `required:"true"`
+		Port int    `default:"5432" required:"true"`
+	}
+	type sample struct {
+		APIKey  string `required:"true" env:"API_KEY"`
+		Token   string `required:"true"`
+		Name    string `default:"app" required:"true"`
+		DB      db
+		Replica *db
+		Shards  []db
+	}
+
+	s := &sample{Token: "t", Replica: &db{DSN: "r"}, Shards: []db{{DSN: "s"}, {}}}
+	err := Set(s)
+	var errs Errors
+	if !errors.As(err, &errs) {
+		t.Fatalf("it should return Errors: %v", err)
+	}
+	var paths []string
+	for _, e := range errs {
+		var fe *FieldError
+		if !errors.As(e, &fe) || !errors.Is(fe, ErrRequired) {
+			t.Errorf("it should return a FieldError of ErrRequired: %v", e)
+			continue
+		}
+		paths = append(paths, fe.Path)
+	}
+	if want := []string{"APIKey", "DB.DSN", "Shards[1].DSN"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("it should list every required field not set: %v, want %v", paths, want)
+	}
+	if s.Name != "app" || s.DB.Port != 5432 || s.Shards[1].Port != 5432 {
+		t.Errorf("it should set default values to the other fields: %+v", s)
+	}
+
+	_, err = Load(&sample{Token: "t", DB: db{DSN: "d"}}, EnvSource("", func(key string) (string, bool) {
+		return "key", key == "API_KEY"
+	}))
+	if err != nil {
+		t.Errorf("it should accept required fields set by sources: %v", err)
+	}
+	if err := UnmarshalJSON([]byte(`{"APIKey": "k", "Token": "t", "DB": {"DSN": "d"}, "Shards": [{}]}`), &sample{}); !errors.Is(err, ErrRequired) {
+		t.Errorf("it should check required fields after decoding: %v", err)
+	}
+	if _, err := Plan(&sample{}); err != nil {
+		t.Errorf("it should not check required fields in Plan: %v", err)
+	}
+}
+
+func TestConstraints(t *testing.T) {
+	type sample struct {
+		Port     int           `default:"8080" min:"1" max:"65535"`
+		Level    string        `default:"info" oneof:"debug info warn error"`
+		Name     string        `default:"app" regexp:"^[a-z]+$"`
+		Timeout  time.Duration `default:"30s" min:"1s" max:"1m"`
+		Tags     []string      `default:"[\"a\"]" min:"1"`
+		Ratio    *float64      `default:"0.5" max:"1"`
+		Optional int           `min:"10"`
+	}
+
+	s := &sample{Port: 70000, Level: "trace", Name: "App", Timeout: time.Hour, Tags: []string{}, Optional: 1}
+	if err := Set(s); err != nil {
+		t.Errorf("it should not check constraints without Validate: %v", err)
+	}
+
+	d := NewDefaulter(Validate())
+	if err := d.Set(&sample{}); err != nil {
+		t.Errorf("it should accept values satisfying constraints: %v", err)
+	}
+
+	s = &sample{Port: 70000, Level: "trace", Name: "App", Timeout: time.Hour, Tags: []string{}, Optional: 1}
+	err := d.Set(s)
+	var errs Errors
+	if !errors.As(err, &errs) {
+		t.Fatalf("it should return Errors: %v", err)
+	}
+	var paths []string
+	for _, e := range errs {
+		var fe *FieldError
+		if !errors.As(e, &fe) || !errors.Is(fe, ErrConstraint) {
+			t.Errorf("it should return a FieldError of ErrConstraint: %v", e)
+			continue
+		}
+		paths = append(paths, fe.Path)
+	}
+	if want := []string{"Port", "Level", "Name", "Timeout", "Tags", "Optional"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("it should check final values: %v, want %v", paths, want)
+	}
+	if len(errs) > 0 && errs[0].Error() != `defaults: Port (int) with default "8080": value 70000 is greater than max 65535` {
+		t.Errorf("it should describe the violation: %v", errs[0])
+	}
+
+	type badDefault struct {
+		Port int `default:"0" min:"1"`
+	}
+	err = d.Set(&badDefault{Port: 80})
+	var fe *FieldError
+	if !errors.As(err, &fe) || !errors.Is(fe, ErrConstraint) || fe.Error() != `defaults: Port (int) with default "0": default value 0 is less than min 1` {
+		t.Errorf("it should check default values even if overridden: %v", err)
+	}
+
+	type overridden struct {
+		Port int `default:"8080" max:"9000" env:"PORT"`
+	}
+	_, err = d.Load(&overridden{}, EnvSource("", func(key string) (string, bool) {
+		return "9090", key == "PORT"
+	}))
+	if !errors.Is(err, ErrConstraint) {
+		t.Errorf("it should check values overridden by sources: %v", err)
+	}
+
+	type unsupported struct {
+		Port int `regexp:"^8"`
+	}
+	if err := d.Set(&unsupported{Port: 80}); !errors.Is(err, ErrUnsupportedKind) {
+		t.Errorf("it should return ErrUnsupportedKind for a constraint on an unsupported kind: %v", err)
+	}
+}
+
+type benchRequest struct {
+	Page    int               `default:"1"`
+	PerPage int               `default:"20"`
+	Sort    string            `default:"created_at"`
+	Order   string            `default:"desc"`
+	Fields  []string          `default:"[\"id\", \"name\"]"`
+	Filters map[string]string `default:"{\"status\": \"active\"}"`
+	Options struct {
+		Timeout time.Duration `default:"1.5s"`
+		Retry   bool          `default:"true"`
+	}
+	Items []struct {
+		Name string `default:"item"`
+	} `default:"[{}, {}]"`
+}
+
+func BenchmarkSet(b *testing.B) {
+	b.Run("cached", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if err := Set(&benchRequest{}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("uncached", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			w := &walker{options: defaultDefaulter.options, plans: &planCache{tagName: fieldName}}
+			if err := w.set(&benchRequest{}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,162 @@
+package defaults
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// Change is a difference in the value of a field.
+type Change struct {
+	Path string      // the path of the field rendered like Origins
+	Old  interface{} // the value before the change, or nil if there is none, e.g. for a map key
+	New  interface{} // the value after the change, or nil if there is none
+}
+
+// Diff returns the differences of a struct referenced by a pointer from its default values,
+// which are set by Set to a new struct. The Old values of changes are the default values.
+// Fields with default values relative to the current time like `now` are not compared,
+// since they differ each time they are set.
+// It returns nil if ptr is not a struct pointer.
+func Diff(ptr interface{}) []Change {
+	return defaultDefaulter.Diff(ptr)
+}
+
+// IsDefault reports whether a struct referenced by a pointer has only its default values.
+func IsDefault(ptr interface{}) bool {
+	return defaultDefaulter.IsDefault(ptr)
+}
+
+// Diff returns the differences from default values like the package-level Diff.
+func (d *Defaulter) Diff(ptr interface{}) []Change {
+	v, err := structOf(ptr)
+	if err != nil {
+		return nil
+	}
+	def := reflect.New(v.Type())
+	_ = d.apply(def.Interface())
+	c := &differ{plans: d.plans}
+	c.diff(def.Elem(), v)
+	return c.changes
+}
+
+// IsDefault reports whether a struct has only its default values like the package-level IsDefault.
+func (d *Defaulter) IsDefault(ptr interface{}) bool {
+	if _, err := structOf(ptr); err != nil {
+		return false
+	}
+	return len(d.Diff(ptr)) == 0
+}
+
+// differ collects the changes from one value to another.
+type differ struct {
+	path    fieldPath
+	changes []Change
+	plans   *planCache // fields with relative time defaults are skipped unless nil
+}
+
+// diff returns the changes from old to new of the same type.
+// Structs are compared field by field, and so are elements of slices and arrays of the same length,
+// values of maps, and values referenced by pointers and interfaces.
+func diff(old, new reflect.Value) []Change {
+	d := &differ{}
+	d.diff(old, new)
+	return d.changes
+}
+
+func (d *differ) diff(old, new reflect.Value) {
+	switch old.Kind() {
+	case reflect.Struct:
+		if !hasExportedField(old.Type()) {
+			break
+		}
+		var plan *structPlan
+		if d.plans != nil {
+			plan = d.plans.planOf(old.Type())
+		}
+		for i := 0; i < old.NumField(); i++ {
+			if !old.Type().Field(i).IsExported() || plan != nil && plan.hasRelativeTime(old.Type(), i) {
+				continue
+			}
+			d.path = append(d.path, pathElem{kind: pathField, name: old.Type().Field(i).Name})
+			d.diff(old.Field(i), new.Field(i))
+			d.path = d.path[:len(d.path)-1]
+		}
+		return
+	case reflect.Ptr, reflect.Interface:
+		if old.IsNil() || new.IsNil() || old.Elem().Type() != new.Elem().Type() {
+			break
+		}
+		d.diff(old.Elem(), new.Elem())
+		return
+	case reflect.Slice, reflect.Array:
+		if old.Len() != new.Len() || old.Kind() == reflect.Slice && old.IsNil() != new.IsNil() {
+			break
+		}
+		for i := 0; i < old.Len(); i++ {
+			d.path = append(d.path, pathElem{kind: pathIndex, index: i})
+			d.diff(old.Index(i), new.Index(i))
+			d.path = d.path[:len(d.path)-1]
+		}
+		return
+	case reflect.Map:
+		if old.IsNil() != new.IsNil() {
+			break
+		}
+		for _, k := range sortedMapKeys(old, new) {
+			d.path = append(d.path, pathElem{kind: pathKey, key: k})
+			o, n := old.MapIndex(k), new.MapIndex(k)
+			if o.IsValid() && n.IsValid() {
+				d.diff(o, n)
+			} else {
+				d.add(o, n)
+			}
+			d.path = d.path[:len(d.path)-1]
+		}
+		return
+	case reflect.Func:
+		// reflect.DeepEqual tells only nil funcs equal
+		if old.Pointer() != new.Pointer() {
+			d.add(old, new)
+		}
+		return
+	}
+
+	if !reflect.DeepEqual(old.Interface(), new.Interface()) {
+		d.add(old, new)
+	}
+}
+
+func (d *differ) add(old, new reflect.Value) {
+	c := Change{Path: d.path.dotted()}
+	if old.IsValid() {
+		c.Old = old.Interface()
+	}
+	if new.IsValid() {
+		c.New = new.Interface()
+	}
+	d.changes = append(d.changes, c)
+}
+
+func hasExportedField(t reflect.Type) bool {
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).IsExported() {
+			return true
+		}
+	}
+	return false
+}
+
+// sortedMapKeys returns the keys of two maps without duplicates in the order of their formatted values.
+func sortedMapKeys(a, b reflect.Value) []reflect.Value {
+	keys := a.MapKeys()
+	for _, k := range b.MapKeys() {
+		if !a.MapIndex(k).IsValid() {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+	})
+	return keys
+}
@@ -0,0 +1,23 @@
+package defaults
+
+import "reflect"
+
+// Plan returns the changes Set would make to a struct referenced by a pointer without modifying it.
+// Set is applied to a deep copy of the struct including its unexported fields, whose setters are called as well.
+func Plan(ptr interface{}) ([]Change, error) {
+	return defaultDefaulter.Plan(ptr)
+}
+
+// Plan returns the changes Set would make like the package-level Plan.
+func (d *Defaulter) Plan(ptr interface{}) ([]Change, error) {
+	v, err := structOf(ptr)
+	if err != nil {
+		return nil, err
+	}
+	c := reflect.New(v.Type())
+	copyValue(c.Elem(), v)
+	if err := d.apply(c.Interface()); err != nil {
+		return nil, err
+	}
+	return diff(v, c.Elem()), nil
+}
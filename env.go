@@ -0,0 +1,65 @@
+package defaults
+
+import (
+	"os"
+	"reflect"
+)
+
+const (
+	envName       = "env"
+	envPrefixName = "envPrefix"
+)
+
+// envKey returns the name of the environment variable of the last field of tags,
+// which is prefix followed by the `envPrefix` tags of the fields containing it and its `env` tag,
+// or false if it has no `env` tag.
+func envKey(prefix string, tags []reflect.StructTag) (string, bool) {
+	last := len(tags) - 1
+	name := tags[last].Get(envName)
+	if name == "" {
+		return "", false
+	}
+	for _, tag := range tags[:last] {
+		prefix += tag.Get(envPrefixName)
+	}
+	return prefix + name, true
+}
+
+// setEnv overrides a field with the value of the environment variable named by its `env` tag,
+// and reports whether the field was overridden.
+// Fields in elements of slices, arrays and maps are not looked up like EnvSource.
+func (w *walker) setEnv(field reflect.Value, tag fieldTag) (bool, error) {
+	if _, ok := w.path.fieldNames(); !ok {
+		return false, nil
+	}
+	key, ok := envKey(w.envPrefix, w.tags)
+	if !ok {
+		return false, nil
+	}
+	lookup := w.lookupEnv
+	if lookup == nil {
+		lookup = os.LookupEnv
+	}
+	val, ok := lookup(key)
+	if !ok {
+		return false, nil
+	}
+	return w.override(field, tag, val, EnvOrigin)
+}
+
+// override sets a field with a value parsed like a default value, regardless of its current value,
+// records origin as the origin of the value, and reports whether the field was set.
+// Unlike a default value, failing to parse the value is always an error.
+func (w *walker) override(field reflect.Value, tag fieldTag, val, origin string) (bool, error) {
+	v := reflect.New(field.Type()).Elem()
+	strict, env, errs := w.strict, w.env, len(w.errs)
+	w.strict, w.env = true, false
+	err := w.setField(v, fieldTag{value: val, layout: tag.layout})
+	w.strict, w.env = strict, env
+	if err != nil || len(w.errs) > errs {
+		return false, err
+	}
+	field.Set(v)
+	w.record(field, tag.value, origin)
+	return true, nil
+}
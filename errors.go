@@ -0,0 +1,122 @@
+package defaults
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrInvalidType is returned when the given value is not a struct pointer.
+	ErrInvalidType = errors.New("not a struct pointer")
+	// ErrNilPointer is returned when the given struct pointer is nil.
+	ErrNilPointer = errors.New("nil pointer")
+	// ErrParse is the kind of a FieldError for a default value that cannot be parsed.
+	ErrParse = errors.New("invalid default value")
+	// ErrOverflow is the kind of a FieldError for a default value out of the range of the field type.
+	ErrOverflow = errors.New("default value out of range")
+	// ErrUnsupportedKind is the kind of a FieldError for a field whose kind cannot have a default value.
+	ErrUnsupportedKind = errors.New("unsupported kind")
+	// ErrSetterFailed is the kind of a FieldError for an ErrorSetter that returned an error.
+	ErrSetterFailed = errors.New("setter failed")
+	// ErrFieldNotFound is returned by ResetField for a path naming no field.
+	ErrFieldNotFound = errors.New("field not found")
+	// ErrRequired is the kind of a FieldError for a required field left with a zero value.
+	ErrRequired = errors.New("required field is not set")
+	// ErrConstraint is the kind of a FieldError for a default value or a value violating
+	// the constraints given by `min`, `max`, `oneof` and `regexp` tags.
+	ErrConstraint = errors.New("constraint violated")
+)
+
+// FieldError is returned when a default value cannot be applied to a field,
+// or a field is not valid after default values are set.
+type FieldError struct {
+	// Path is the location of the field from the root struct, e.g. `Server.Listeners[2].Timeout`.
+	// A pointer hop is denoted as a dereference, e.g. `(*Server.TLS).CertFile`.
+	Path string
+	// Tag is the raw default value declared for the field.
+	Tag string
+	// Type is the type of the field.
+	Type reflect.Type
+	// Kind is one of the sentinel errors classifying the failure, e.g. ErrParse.
+	Kind error
+	// Err is the underlying error.
+	Err error
+}
+
+func newFieldError(path, tag string, typ reflect.Type, err error) *FieldError {
+	kind := ErrParse
+	var numErr *strconv.NumError
+	switch {
+	case errors.Is(err, ErrUnsupportedKind):
+		kind = ErrUnsupportedKind
+	case errors.As(err, &numErr) && numErr.Err == strconv.ErrRange:
+		kind = ErrOverflow
+	}
+	return &FieldError{Path: path, Tag: tag, Type: typ, Kind: kind, Err: err}
+}
+
+func (e *FieldError) Error() string {
+	var b strings.Builder
+	b.WriteString("defaults: ")
+	if e.Path != "" {
+		b.WriteString(e.Path)
+		b.WriteString(" ")
+	}
+	fmt.Fprintf(&b, "(%s)", e.Type)
+	if e.Tag != "" {
+		fmt.Fprintf(&b, " with default %q", e.Tag)
+	}
+	fmt.Fprintf(&b, ": %v", e.Err)
+	return b.String()
+}
+
+// Unwrap returns the underlying error.
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is the kind of the error.
+func (e *FieldError) Is(target error) bool {
+	return e.Kind != nil && e.Kind == target
+}
+
+// Errors is a list of errors collected with the AllErrors option or by validating fields.
+// Each of them is reachable through errors.Is and errors.As,
+// by its Is and As methods before Go 1.20 and by Unwrap since then.
+type Errors []error
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Unwrap returns the collected errors.
+func (e Errors) Unwrap() []error {
+	return e
+}
+
+// Is reports whether any of the collected errors matches target.
+func (e Errors) Is(target error) bool {
+	for _, err := range e {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first of the collected errors that matches target, and if so, sets target to it.
+func (e Errors) As(target interface{}) bool {
+	for _, err := range e {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,28 @@
+package defaults
+
+// New allocates a T and initializes it with default values.
+// T should be a struct type; otherwise ErrInvalidType is returned.
+func New[T any](opts ...Option) (*T, error) {
+	ptr := new(T)
+	if err := SetWithOptions(ptr, opts...); err != nil {
+		return nil, err
+	}
+	return ptr, nil
+}
+
+// MustNew is like New but panics if an error occurs.
+func MustNew[T any](opts ...Option) *T {
+	ptr, err := New[T](opts...)
+	if err != nil {
+		panic(err)
+	}
+	return ptr
+}
+
+// Value returns a T initialized with default values.
+// T should be a struct type; otherwise ErrInvalidType is returned.
+func Value[T any](opts ...Option) (T, error) {
+	var v T
+	err := SetWithOptions(&v, opts...)
+	return v, err
+}
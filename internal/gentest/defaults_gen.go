@@ -0,0 +1,283 @@
+// Code generated by defaults-gen; DO NOT EDIT.
+
+package gentest
+
+import (
+	"github.com/creasty/defaults"
+	"time"
+)
+
+// SetDefaults sets the default values given by `default` tags.
+func (s *Collections) SetDefaults() {
+	if s.Slice == nil {
+		s.Slice = []int{1, 2, 3}
+	}
+	if s.EmptySlice == nil {
+		s.EmptySlice = []string{}
+	}
+	if s.Bytes == nil {
+		s.Bytes = []byte{104, 105}
+	}
+	if s.Array == ([3]int{}) {
+		s.Array = [3]int{1, 2}
+	}
+	if s.Map == nil {
+		s.Map = map[string]int{"a": 1}
+	}
+	if s.EmptyMap == nil {
+		s.EmptyMap = map[string]int{}
+	}
+	if s.IntKeys == nil {
+		s.IntKeys = map[int]string{1: "one"}
+	}
+	if s.Structs == nil {
+		s.Structs = []Nested{{Name: "x"}, {}}
+	}
+	for i0 := range s.Structs {
+		s.Structs[i0].SetDefaults()
+	}
+	if s.StructPtrs == nil {
+		s.StructPtrs = []*Nested{{Name: "y", Embedded: Embedded{Level: 3}}, nil}
+	}
+	for i0 := range s.StructPtrs {
+		if s.StructPtrs[i0] != nil {
+			s.StructPtrs[i0].SetDefaults()
+		}
+	}
+	for i0 := range s.StructArray {
+		s.StructArray[i0].SetDefaults()
+	}
+	if s.StructMap == nil {
+		s.StructMap = map[string]Nested{"a": {}}
+	}
+	for k0, v0 := range s.StructMap {
+		v0.SetDefaults()
+		s.StructMap[k0] = v0
+	}
+	if s.StructPtrMap == nil {
+		s.StructPtrMap = map[string]*Nested{"a": {}, "b": nil}
+	}
+	for _, v0 := range s.StructPtrMap {
+		if v0 != nil {
+			v0.SetDefaults()
+		}
+	}
+	if s.Any == nil {
+		s.Any = map[string]interface{}{"a": []interface{}{float64(1), "b", true, nil}}
+	}
+}
+
+// GeneratedDefaults implements defaults.GeneratedSetter.
+func (*Collections) GeneratedDefaults() string { return "default" }
+
+// SetDefaults sets the default values given by `default` tags.
+func (s *Composite) SetDefaults() {
+	if s.Nested.Name == "" && s.Nested.Count == 0 && s.Nested.Tags == nil && s.Nested.Embedded == (Embedded{}) {
+		s.Nested = Nested{Name: "json", Embedded: Embedded{Level: 9}}
+	}
+	s.Nested.SetDefaults()
+	if s.Anonymous.Value == 0 {
+		s.Anonymous.Value = 3
+	}
+	s.Anonymous.Inner.SetDefaults()
+	_ = defaults.Set(&s.Hooked)
+	if s.HookedPtr == nil {
+		s.HookedPtr = new(Hooked)
+	}
+	if s.HookedPtr != nil {
+		_ = defaults.Set(s.HookedPtr)
+	}
+}
+
+// GeneratedDefaults implements defaults.GeneratedSetter.
+func (*Composite) GeneratedDefaults() string { return "default" }
+
+// SetDefaults sets the default values given by `default` tags.
+func (s *Embedded) SetDefaults() {
+	if s.Level == 0 {
+		s.Level = 2
+	}
+}
+
+// GeneratedDefaults implements defaults.GeneratedSetter.
+func (*Embedded) GeneratedDefaults() string { return "default" }
+
+// SetDefaults sets the default values given by `default` tags.
+func (s *Nested) SetDefaults() {
+	if s.Name == "" {
+		s.Name = "nested"
+	}
+	if s.Count == 0 {
+		s.Count = 1
+	}
+	if s.Tags == nil {
+		s.Tags = []string{"a"}
+	}
+	s.Embedded.SetDefaults()
+}
+
+// GeneratedDefaults implements defaults.GeneratedSetter.
+func (*Nested) GeneratedDefaults() string { return "default" }
+
+// SetDefaults sets the default values given by `default` tags.
+func (s *Pointers) SetDefaults() {
+	if s.Int == nil {
+		s.Int = new(int)
+		if *s.Int == 0 {
+			*s.Int = 1
+		}
+	}
+	if s.String == nil {
+		s.String = new(string)
+		if *s.String == "" {
+			*s.String = "s"
+		}
+	}
+	if s.MyInt == nil {
+		s.MyInt = new(MyInt)
+		if *s.MyInt == 0 {
+			*s.MyInt = 3
+		}
+	}
+	if s.PtrPtr == nil {
+		s.PtrPtr = new(*int)
+		if *s.PtrPtr == nil {
+			*s.PtrPtr = new(int)
+			if **s.PtrPtr == 0 {
+				**s.PtrPtr = 5
+			}
+		}
+	}
+	if s.Struct == nil {
+		s.Struct = new(Nested)
+	}
+	if s.Struct != nil {
+		if s.Struct.Name == "" && s.Struct.Count == 0 && s.Struct.Tags == nil && s.Struct.Embedded == (Embedded{}) {
+			*s.Struct = Nested{Count: 3}
+		}
+		s.Struct.SetDefaults()
+	}
+	if s.NilStruct != nil {
+		s.NilStruct.SetDefaults()
+	}
+	if s.Set != nil {
+		s.Set.SetDefaults()
+	}
+}
+
+// GeneratedDefaults implements defaults.GeneratedSetter.
+func (*Pointers) GeneratedDefaults() string { return "default" }
+
+// SetDefaults sets the default values given by `default` tags.
+func (s *Relative) SetDefaults() {
+	if s.Now == (time.Time{}) {
+		s.Now = time.Now()
+	}
+	if s.Later == (time.Time{}) {
+		s.Later = time.Now().Add(time.Hour)
+	}
+	if s.Today == (time.Time{}) {
+		y, m, d := time.Now().Date()
+		s.Today = time.Date(y, m, d, 0, 0, 0, 0, time.Local).Add(-24 * time.Hour)
+	}
+}
+
+// GeneratedDefaults implements defaults.GeneratedSetter.
+func (*Relative) GeneratedDefaults() string { return "default" }
+
+// SetDefaults sets the default values given by `default` tags.
+func (s *Scalars) SetDefaults() {
+	if !s.Bool {
+		s.Bool = true
+	}
+	if s.Int == 0 {
+		s.Int = -1
+	}
+	if s.Int8 == 0 {
+		s.Int8 = 127
+	}
+	if s.Int16 == 0 {
+		s.Int16 = 1000
+	}
+	if s.Int32 == 0 {
+		s.Int32 = -32
+	}
+	if s.Int64 == 0 {
+		s.Int64 = 64
+	}
+	if s.Uint == 0 {
+		s.Uint = 1
+	}
+	if s.Uint8 == 0 {
+		s.Uint8 = 8
+	}
+	if s.Uint16 == 0 {
+		s.Uint16 = 16
+	}
+	if s.Uint32 == 0 {
+		s.Uint32 = 32
+	}
+	if s.Uint64 == 0 {
+		s.Uint64 = 18446744073709551615
+	}
+	if s.Uintptr == 0 {
+		s.Uintptr = 255
+	}
+	if s.Float32 == 0 {
+		s.Float32 = 1.32
+	}
+	if s.Float64 == 0 {
+		s.Float64 = 0.001
+	}
+	if s.Complex64 == 0 {
+		s.Complex64 = complex(1, 2)
+	}
+	if s.Complex128 == 0 {
+		s.Complex128 = complex(0, -3.5)
+	}
+	if s.String == "" {
+		s.String = "hello"
+	}
+	if s.MyInt == 0 {
+		s.MyInt = 7
+	}
+	if s.MyString == "" {
+		s.MyString = "named"
+	}
+	if s.Duration == 0 {
+		s.Duration = 90 * time.Minute
+	}
+	if s.DurationInt == 0 {
+		s.DurationInt = 1500
+	}
+	if s.IP == nil {
+		_ = s.IP.UnmarshalText([]byte("::1"))
+	}
+}
+
+// GeneratedDefaults implements defaults.GeneratedSetter.
+func (*Scalars) GeneratedDefaults() string { return "default" }
+
+// SetDefaults sets the default values given by `default` tags.
+func (s *Times) SetDefaults() {
+	if s.Layout == (time.Time{}) {
+		s.Layout, _ = time.Parse("2006-01-02", "2024-01-02")
+	}
+	if s.RFC3339 == (time.Time{}) {
+		s.RFC3339, _ = time.Parse(time.RFC3339, "2024-01-02T03:04:05Z")
+	}
+	if s.Epoch == (time.Time{}) {
+		s.Epoch = time.Unix(1700000000, 0)
+	}
+	if s.Ptr == nil {
+		s.Ptr = new(time.Time)
+	}
+	if s.Ptr != nil {
+		if *s.Ptr == (time.Time{}) {
+			*s.Ptr, _ = time.Parse(time.RFC3339, "2024-01-02T03:04:05+09:00")
+		}
+	}
+}
+
+// GeneratedDefaults implements defaults.GeneratedSetter.
+func (*Times) GeneratedDefaults() string { return "default" }
@@ -0,0 +1,115 @@
+// Package gentest declares types whose SetDefaults is generated by cmd/defaults-gen
+// to test the generated code against the reflective walk.
+package gentest
+
+import (
+	"net"
+	"time"
+)
+
+//go:generate go run ../../cmd/defaults-gen
+
+type MyInt int
+
+type MyString string
+
+type Scalars struct {
+	Bool        bool          `default:"true"`
+	Int         int           `default:"-1"`
+	Int8        int8          `default:"0x7f"`
+	Int16       int16         `default:"1_000"`
+	Int32       int32         `default:"-32"`
+	Int64       int64         `default:"64"`
+	Uint        uint          `default:"1"`
+	Uint8       uint8         `default:"0o10"`
+	Uint16      uint16        `default:"16"`
+	Uint32      uint32        `default:"32"`
+	Uint64      uint64        `default:"18446744073709551615"`
+	Uintptr     uintptr       `default:"0xff"`
+	Float32     float32       `default:"1.32"`
+	Float64     float64       `default:"1e-3"`
+	Complex64   complex64     `default:"1+2i"`
+	Complex128  complex128    `default:"-3.5i"`
+	String      string        `default:"hello"`
+	MyInt       MyInt         `default:"7"`
+	MyString    MyString      `default:"named"`
+	Duration    time.Duration `default:"1h30m"`
+	DurationInt time.Duration `default:"1500"`
+	IP          net.IP        `default:"::1"`
+	NoDefault   int
+	Skipped     int `default:"-"`
+	unexported  int `default:"1"`
+}
+
+type Times struct {
+	Layout  time.Time  `default:"2024-01-02" layout:"2006-01-02"`
+	RFC3339 time.Time  `default:"2024-01-02T03:04:05Z"`
+	Epoch   time.Time  `default:"1700000000"`
+	Ptr     *time.Time `default:"2024-01-02T03:04:05+09:00"`
+	None    time.Time
+}
+
+type Relative struct {
+	Now   time.Time `default:"now"`
+	Later time.Time `default:"now+1h"`
+	Today time.Time `default:"today-24h"`
+}
+
+type Nested struct {
+	Name  string   `default:"nested"`
+	Count int      `default:"1"`
+	Tags  []string `default:"[\"a\"]"`
+	Embedded
+}
+
+type Embedded struct {
+	Level int `default:"2"`
+}
+
+type Collections struct {
+	Slice        []int          `default:"[1, 2, 3]"`
+	EmptySlice   []string       `default:"[]"`
+	Bytes        []byte         `default:"\"aGk=\""`
+	Array        [3]int         `default:"[1, 2]"`
+	Map          map[string]int `default:"{\"a\": 1}"`
+	EmptyMap     map[string]int `default:"{}"`
+	IntKeys      map[int]string `default:"{\"1\": \"one\"}"`
+	Structs      []Nested       `default:"[{\"Name\": \"x\"}, {}]"`
+	StructPtrs   []*Nested      `default:"[{\"name\": \"y\", \"level\": 3}, null]"`
+	StructArray  [2]Nested
+	StructMap    map[string]Nested  `default:"{\"a\": {}}"`
+	StructPtrMap map[string]*Nested `default:"{\"a\": {}, \"b\": null}"`
+	Any          interface{}        `default:"{\"a\": [1, \"b\", true, null]}"`
+}
+
+type Pointers struct {
+	Int       *int    `default:"1"`
+	String    *string `default:"s"`
+	MyInt     *MyInt  `default:"3"`
+	PtrPtr    **int   `default:"5"`
+	Struct    *Nested `default:"{\"count\": 3}"`
+	NilStruct *Nested
+	Set       *Nested
+}
+
+type Composite struct {
+	Nested    Nested `default:"{\"Name\": \"json\", \"Level\": 9}"`
+	Anonymous struct {
+		Value int `default:"3"`
+		Inner Nested
+	}
+	Hooked    Hooked
+	HookedPtr *Hooked `default:"{}"`
+}
+
+// Hooked has its own SetDefaults, so it is set by the reflective walk.
+type Hooked struct {
+	Value   int `default:"1"`
+	Doubled int
+}
+
+func (h *Hooked) SetDefaults() {
+	if h.Doubled == 0 {
+		h.Doubled = h.Value * 2
+	}
+}
@@ -0,0 +1,76 @@
+package gentest
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/creasty/defaults"
+)
+
+func TestGenerated(t *testing.T) {
+	seven := 7
+	cases := []struct {
+		name string
+		new  func() defaults.GeneratedSetter
+	}{
+		{"Scalars", func() defaults.GeneratedSetter { return &Scalars{} }},
+		{"Scalars with values", func() defaults.GeneratedSetter { return &Scalars{Int: 5, String: "set", Bool: true} }},
+		{"Times", func() defaults.GeneratedSetter { return &Times{} }},
+		{"Collections", func() defaults.GeneratedSetter { return &Collections{} }},
+		{"Collections with values", func() defaults.GeneratedSetter {
+			return &Collections{
+				Slice:        []int{9},
+				Map:          map[string]int{},
+				Structs:      []Nested{{Count: 5}},
+				StructMap:    map[string]Nested{"b": {Name: "b"}},
+				StructPtrMap: map[string]*Nested{"b": {}, "c": nil},
+			}
+		}},
+		{"Pointers", func() defaults.GeneratedSetter { return &Pointers{} }},
+		{"Pointers with values", func() defaults.GeneratedSetter {
+			return &Pointers{Int: &seven, Struct: &Nested{Name: "set"}, Set: &Nested{}}
+		}},
+		{"Composite", func() defaults.GeneratedSetter { return &Composite{} }},
+		{"Composite with values", func() defaults.GeneratedSetter {
+			return &Composite{Nested: Nested{Count: 2}, Hooked: Hooked{Value: 4}}
+		}},
+	}
+
+	for _, c := range cases {
+		generated, reflective := c.new(), c.new()
+		generated.SetDefaults()
+		if err := defaults.SetWithOptions(reflective, defaults.IgnoreGenerated()); err != nil {
+			t.Fatalf("%s: it should not return an error: %v", c.name, err)
+		}
+		if !reflect.DeepEqual(generated, reflective) {
+			t.Errorf("%s: it should set the same values as the reflective walk\ngenerated:  %+v\nreflective: %+v", c.name, generated, reflective)
+		}
+
+		set := c.new()
+		if err := defaults.Set(set); err != nil {
+			t.Fatalf("%s: it should not return an error: %v", c.name, err)
+		}
+		if !reflect.DeepEqual(set, generated) {
+			t.Errorf("%s: it should set the values of the generated SetDefaults\nset:       %+v\ngenerated: %+v", c.name, set, generated)
+		}
+	}
+}
+
+func TestGeneratedRelative(t *testing.T) {
+	before := time.Now()
+	r := &Relative{}
+	r.SetDefaults()
+	after := time.Now()
+
+	if r.Now.Before(before) || r.Now.After(after) {
+		t.Errorf("it should set the current time to Now, got %v", r.Now)
+	}
+	if d := r.Later.Sub(r.Now); d < time.Hour || d > time.Hour+after.Sub(before) {
+		t.Errorf("it should set an hour later to Later, got %v", r.Later)
+	}
+	y, m, d := r.Now.Date()
+	if today := time.Date(y, m, d, 0, 0, 0, 0, time.Local).Add(-24 * time.Hour); !r.Today.Equal(today) {
+		t.Errorf("it should set the start of yesterday to Today, got %v", r.Today)
+	}
+}
@@ -0,0 +1,92 @@
+package defaults
+
+import "time"
+
+// Option configures the behavior of SetWithOptions and Defaulter.
+type Option func(*options)
+
+type options struct {
+	strict          bool
+	allErrors       bool
+	now             func() time.Time
+	int64AsDuration bool
+	tagName         string
+	ignoreGenerated bool
+	env             bool
+	envPrefix       string
+	lookupEnv       func(key string) (string, bool)
+	validateFields  bool
+}
+
+// Strict makes an unparsable or out-of-range default value an error
+// instead of leaving the field with its zero value.
+func Strict() Option {
+	return func(o *options) {
+		o.strict = true
+	}
+}
+
+// AllErrors makes SetWithOptions walk the whole struct tree even after a failure
+// and return every error at once as Errors.
+func AllErrors() Option {
+	return func(o *options) {
+		o.allErrors = true
+	}
+}
+
+// Clock replaces time.Now used to resolve relative time defaults such as `now` and `today`.
+func Clock(now func() time.Time) Option {
+	return func(o *options) {
+		o.now = now
+	}
+}
+
+// Int64AsDuration restores the former behavior of parsing a default value of any int64 field
+// as a duration like `1h` first, not only of time.Duration.
+func Int64AsDuration() Option {
+	return func(o *options) {
+		o.int64AsDuration = true
+	}
+}
+
+// TagName changes the key of struct tags holding default values from `default`.
+func TagName(name string) Option {
+	return func(o *options) {
+		o.tagName = name
+	}
+}
+
+// IgnoreGenerated makes SetWithOptions walk the fields of a GeneratedSetter with reflection
+// instead of calling its generated SetDefaults.
+func IgnoreGenerated() Option {
+	return func(o *options) {
+		o.ignoreGenerated = true
+	}
+}
+
+// Env makes the environment variables named by `env` tags override the values of fields.
+// The values are parsed like default values, and the names are prefixed with prefix
+// followed by the `envPrefix` tags of the structs containing the fields.
+// Fields in elements of slices, arrays and maps are not looked up.
+func Env(prefix string) Option {
+	return func(o *options) {
+		o.env = true
+		o.envPrefix = prefix
+	}
+}
+
+// Validate makes Set, Load, SetWithReport and UnmarshalJSON check the `min`, `max`, `oneof` and `regexp` tags
+// after default values, setters and overrides are applied, like the `required` tag is always checked.
+// Without it, these tags are ignored.
+func Validate() Option {
+	return func(o *options) {
+		o.validateFields = true
+	}
+}
+
+// EnvLookup replaces os.LookupEnv used to read environment variables with Env.
+func EnvLookup(lookup func(key string) (string, bool)) Option {
+	return func(o *options) {
+		o.lookupEnv = lookup
+	}
+}
@@ -0,0 +1,99 @@
+package defaults
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// fieldPath is the location of a field from the root struct.
+// A walker keeps it as a stack and renders it to a string only when needed,
+// so that walking a struct does not allocate for paths.
+type fieldPath []pathElem
+
+type pathElem struct {
+	kind  pathKind
+	name  string        // a field name
+	index int           // an index of a slice or array
+	key   reflect.Value // a key of a map
+}
+
+type pathKind int
+
+const (
+	pathField pathKind = iota
+	pathIndex
+	pathKey
+	pathDeref
+)
+
+// String renders the path, e.g. `Server.Listeners[2].Timeout` or `(*Server.TLS).CertFile`.
+func (p fieldPath) String() string {
+	return p.render(true)
+}
+
+// dotted renders the path without dereferences of pointers, e.g. `Server.TLS.CertFile`.
+func (p fieldPath) dotted() string {
+	return p.render(false)
+}
+
+// fieldNames returns the names of the fields in the path,
+// or false if the path goes through an element of a slice, an array or a map.
+func (p fieldPath) fieldNames() ([]string, bool) {
+	names := make([]string, 0, len(p))
+	for _, e := range p {
+		switch e.kind {
+		case pathField:
+			names = append(names, e.name)
+		case pathIndex, pathKey:
+			return nil, false
+		}
+	}
+	return names, true
+}
+
+func (p fieldPath) render(deref bool) string {
+	var b strings.Builder
+	for _, e := range p {
+		switch e.kind {
+		case pathField:
+			if b.Len() > 0 {
+				b.WriteByte('.')
+			}
+			b.WriteString(e.name)
+		case pathIndex:
+			b.WriteString("[" + strconv.Itoa(e.index) + "]")
+		case pathKey:
+			fmt.Fprintf(&b, "[%v]", e.key.Interface())
+		case pathDeref:
+			if !deref {
+				continue
+			}
+			s := b.String()
+			b.Reset()
+			b.WriteString("(*" + s + ")")
+		}
+	}
+	return b.String()
+}
+
+func (w *walker) pushField(name string) {
+	w.path = append(w.path, pathElem{kind: pathField, name: name})
+}
+
+func (w *walker) pushIndex(index int) {
+	w.path = append(w.path, pathElem{kind: pathIndex, index: index})
+}
+
+func (w *walker) pushKey(key reflect.Value) {
+	w.path = append(w.path, pathElem{kind: pathKey, key: key})
+}
+
+func (w *walker) pushDeref() {
+	w.path = append(w.path, pathElem{kind: pathDeref})
+}
+
+func (w *walker) pop() {
+	w.path = w.path[:len(w.path)-1]
+}
@@ -0,0 +1,113 @@
+package defaults
+
+import (
+	"reflect"
+	"strconv"
+	"sync"
+)
+
+// planCaches holds a planCache for each set of options affecting how tags are read and parsed.
+var planCaches sync.Map // map[planCacheKey]*planCache
+
+type planCacheKey struct {
+	tagName         string
+	int64AsDuration bool
+}
+
+// planCache holds a structPlan for each struct type.
+type planCache struct {
+	plans     sync.Map // map[reflect.Type]*structPlan
+	validated sync.Map // map[reflect.Type]checks
+	tagName   string
+}
+
+func planCacheFor(o *options) *planCache {
+	key := planCacheKey{tagName: o.tagName, int64AsDuration: o.int64AsDuration}
+	if c, ok := planCaches.Load(key); ok {
+		return c.(*planCache)
+	}
+	c, _ := planCaches.LoadOrStore(key, &planCache{tagName: o.tagName})
+	return c.(*planCache)
+}
+
+// structPlan is the compiled form of the tags of a struct type.
+type structPlan struct {
+	fields []fieldPlan
+}
+
+// fieldPlan describes a field of a struct to which default values can be applied.
+type fieldPlan struct {
+	index       int
+	name        string
+	tag         fieldTag
+	tags        reflect.StructTag
+	required    bool
+	constraints *constraints // nil if the field has no constraints
+}
+
+func (c *planCache) planOf(t reflect.Type) *structPlan {
+	if p, ok := c.plans.Load(t); ok {
+		return p.(*structPlan)
+	}
+
+	p := &structPlan{}
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		defaultVal := sf.Tag.Get(c.tagName)
+		if defaultVal == "-" {
+			continue
+		}
+		required, _ := strconv.ParseBool(sf.Tag.Get(requiredName))
+		p.fields = append(p.fields, fieldPlan{
+			index: i,
+			name:  sf.Name,
+			tag: fieldTag{
+				value:  defaultVal,
+				layout: sf.Tag.Get(layoutName),
+				env:    sf.Tag.Get(envName),
+				cache:  &valueCache{},
+			},
+			tags:        sf.Tag,
+			required:    required,
+			constraints: constraintsOf(sf.Tag),
+		})
+	}
+
+	actual, _ := c.plans.LoadOrStore(t, p)
+	return actual.(*structPlan)
+}
+
+// field returns the plan of the field with a name, or nil if there is none.
+func (p *structPlan) field(name string) *fieldPlan {
+	for i := range p.fields {
+		if p.fields[i].name == name {
+			return &p.fields[i]
+		}
+	}
+	return nil
+}
+
+// valueCache holds the values parsed from the default value of a field for each type.
+// A field can have more than one type to parse for, e.g. `*int` and `int`.
+type valueCache struct {
+	values sync.Map // map[reflect.Type]*parsedValue
+}
+
+// parse returns the value parsed for a type, which is cached only for scalar kinds.
+// Slices, arrays, maps and structs are decoded on each use, since an UnmarshalJSON may fill
+// unexported maps, slices or pointers of them, which would be shared by every field set with a cached value.
+func (c *valueCache) parse(typ reflect.Type, defaultVal string, int64AsDuration bool) parsedValue {
+	switch typ.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map, reflect.Struct:
+		return parseValue(typ, defaultVal, int64AsDuration)
+	}
+	if p, ok := c.values.Load(typ); ok {
+		return *p.(*parsedValue)
+	}
+	p := parseValue(typ, defaultVal, int64AsDuration)
+	c.values.Store(typ, &p)
+	return p
+}
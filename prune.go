@@ -0,0 +1,238 @@
+package defaults
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+)
+
+// Prune zeroes the fields of a struct referenced by a pointer holding their default values,
+// so that only the values differing from the defaults are left.
+// Nested structs are pruned field by field, and the other values are compared as a whole.
+// Fields with default values relative to the current time like `now` are kept as they are,
+// since Set would restore them with a different time.
+// Set restores the pruned fields, but not the fields set to zero against their default values.
+func Prune(ptr interface{}) error {
+	return defaultDefaulter.Prune(ptr)
+}
+
+// MarshalJSONMinimal returns the JSON encoding of a struct or a pointer to it
+// without the members holding their default values.
+// Nested objects are reduced member by member, and the other values are compared as a whole.
+// Like Prune, the members of fields with default values relative to the current time are kept.
+func MarshalJSONMinimal(v interface{}) ([]byte, error) {
+	return defaultDefaulter.MarshalJSONMinimal(v)
+}
+
+// Prune zeroes fields holding their default values like the package-level Prune.
+func (d *Defaulter) Prune(ptr interface{}) error {
+	v, err := structOf(ptr)
+	if err != nil {
+		return err
+	}
+	def := reflect.New(v.Type())
+	if err := d.apply(def.Interface()); err != nil {
+		return err
+	}
+	d.pruneStruct(v, def.Elem())
+	return nil
+}
+
+// MarshalJSONMinimal returns the JSON encoding without default values like the package-level MarshalJSONMinimal.
+func (d *Defaulter) MarshalJSONMinimal(v interface{}) ([]byte, error) {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, ErrInvalidType
+	}
+	def := reflect.New(t)
+	if err := d.apply(def.Interface()); err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	obj, err := decodeJSONObject(data)
+	if err != nil {
+		return data, nil
+	}
+	defData, err := json.Marshal(def.Interface())
+	if err != nil {
+		return nil, err
+	}
+	defObj, err := decodeJSONObject(defData)
+	if err != nil {
+		return data, nil
+	}
+	return json.Marshal(minimalJSON(obj, d.dropRelativeTimes(defObj, t)))
+}
+
+func (d *Defaulter) pruneStruct(v, def reflect.Value) {
+	plan := d.plans.planOf(v.Type())
+	for i := 0; i < v.NumField(); i++ {
+		f, df := v.Field(i), def.Field(i)
+		if !f.CanSet() || plan.hasRelativeTime(v.Type(), i) {
+			continue
+		}
+		switch {
+		case reflect.DeepEqual(f.Interface(), df.Interface()):
+			f.Set(reflect.Zero(f.Type()))
+		case f.Kind() == reflect.Struct && hasExportedField(f.Type()):
+			d.pruneStruct(f, df)
+		case f.Kind() == reflect.Ptr && !f.IsNil() && !df.IsNil() && f.Elem().Kind() == reflect.Struct:
+			d.pruneStruct(f.Elem(), df.Elem())
+		}
+	}
+}
+
+// dropRelativeTimes removes the members of the fields with relative time defaults from def,
+// the encoded default values of a struct type, so that minimalJSON keeps them.
+func (d *Defaulter) dropRelativeTimes(def jsonObject, t reflect.Type) jsonObject {
+	out := make(jsonObject, 0, len(def))
+	for _, m := range def {
+		f := jsonFieldsOf(t).match(m.key)
+		if f == nil {
+			out = append(out, m)
+			continue
+		}
+		// the struct declaring the field, which may be embedded
+		st := t
+		for _, i := range f.index[:len(f.index)-1] {
+			st = st.Field(i).Type
+			if st.Kind() == reflect.Ptr {
+				st = st.Elem()
+			}
+		}
+		index := f.index[len(f.index)-1]
+		if d.plans.planOf(st).hasRelativeTime(st, index) {
+			continue
+		}
+		ft := st.Field(index).Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if o, ok := m.value.(jsonObject); ok && ft.Kind() == reflect.Struct && !reflect.PtrTo(ft).Implements(jsonMarshalerType) {
+			m.value = d.dropRelativeTimes(o, ft)
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+var jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+
+// minimalJSON returns the members of obj differing from those of def.
+func minimalJSON(obj, def jsonObject) jsonObject {
+	out := jsonObject{}
+	for _, m := range obj {
+		dv, ok := def.get(m.key)
+		if ok && reflect.DeepEqual(m.value, dv) {
+			continue
+		}
+		if o, isObj := m.value.(jsonObject); isObj && ok {
+			if do, ok := dv.(jsonObject); ok {
+				if m.value = minimalJSON(o, do); len(m.value.(jsonObject)) == 0 {
+					continue
+				}
+			}
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+// jsonObject is a JSON object keeping the order of its members.
+type jsonObject []jsonMember
+
+type jsonMember struct {
+	key   string
+	value interface{}
+}
+
+func (o jsonObject) get(key string) (interface{}, bool) {
+	for _, m := range o {
+		if m.key == key {
+			return m.value, true
+		}
+	}
+	return nil, false
+}
+
+// MarshalJSON implements json.Marshaler.
+func (o jsonObject) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, m := range o {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		key, err := json.Marshal(m.key)
+		if err != nil {
+			return nil, err
+		}
+		val, err := json.Marshal(m.value)
+		if err != nil {
+			return nil, err
+		}
+		b.Write(key)
+		b.WriteByte(':')
+		b.Write(val)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+// decodeJSONObject decodes a JSON object into a jsonObject, keeping numbers as written.
+func decodeJSONObject(data []byte) (jsonObject, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	v, err := decodeOrdered(dec)
+	if err != nil {
+		return nil, err
+	}
+	obj, ok := v.(jsonObject)
+	if !ok {
+		return nil, ErrInvalidType
+	}
+	return obj, nil
+}
+
+func decodeOrdered(dec *json.Decoder) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok {
+	case json.Delim('{'):
+		obj := jsonObject{}
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			val, err := decodeOrdered(dec)
+			if err != nil {
+				return nil, err
+			}
+			obj = append(obj, jsonMember{key: key.(string), value: val})
+		}
+		_, err := dec.Token()
+		return obj, err
+	case json.Delim('['):
+		arr := []interface{}{}
+		for dec.More() {
+			val, err := decodeOrdered(dec)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, val)
+		}
+		_, err := dec.Token()
+		return arr, err
+	}
+	return tok, nil
+}
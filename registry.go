@@ -0,0 +1,65 @@
+package defaults
+
+import (
+	"reflect"
+	"sync"
+)
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string][]reflect.Type)
+	parsers    = make(map[reflect.Type]ParseFunc)
+)
+
+// ParseFunc parses a default value into a value of the type it is registered for.
+type ParseFunc func(s string) (interface{}, error)
+
+// Register makes the type of v available by name as a default value of fields of non-empty interface types.
+// A field of an interface type implemented by the type is filled with a new instance of it,
+// to which default values are applied recursively.
+// If Register is called twice with the same name for types implementing the same interface,
+// the latter wins.
+func Register(name string, v interface{}) {
+	if v == nil {
+		panic("defaults: Register value is nil")
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[name] = append(registry[name], reflect.TypeOf(v))
+}
+
+// lookupType returns the type registered by name that implements iface.
+func lookupType(name string, iface reflect.Type) (reflect.Type, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	types := registry[name]
+	for i := len(types) - 1; i >= 0; i-- {
+		if types[i].Implements(iface) {
+			return types[i], true
+		}
+	}
+	return nil, false
+}
+
+// RegisterParser makes fields of the type of v parsed by fn, in preference to any other method.
+// It can be used to give a type the semantics of another, e.g. durations to a named int64 type.
+// The value returned by fn is converted to the type of the field.
+func RegisterParser(v interface{}, fn ParseFunc) {
+	if v == nil || fn == nil {
+		panic("defaults: RegisterParser value or parser is nil")
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	parsers[reflect.TypeOf(v)] = fn
+}
+
+func lookupParser(typ reflect.Type) (ParseFunc, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	fn, ok := parsers[typ]
+	return fn, ok
+}
@@ -0,0 +1,117 @@
+package defaults
+
+import "reflect"
+
+// Origins of values in a Report in addition to DefaultOrigin and the names of sources.
+const (
+	PreservedOrigin = "preserved" // a non-zero value kept as is
+	SetterOrigin    = "setter"    // a value changed by a Setter or an ErrorSetter
+	EnvOrigin       = envName     // a value of an environment variable given with the Env option
+)
+
+// FieldReport describes where the final value of a field came from.
+type FieldReport struct {
+	Path   string      // the path of the field rendered like Origins
+	Origin string      // DefaultOrigin, PreservedOrigin, SetterOrigin, EnvOrigin or the name of a source
+	Tag    string      // the default value given by the tag
+	Value  interface{} // the final value
+}
+
+// Report lists the fields with default values or changed by setters in the order they are walked.
+type Report []FieldReport
+
+// SetWithReport is like SetWithOptions but also returns a report of the fields.
+func SetWithReport(ptr interface{}, opts ...Option) (Report, error) {
+	return NewDefaulter(opts...).SetWithReport(ptr)
+}
+
+// SetWithReport is like Set but also returns a report of the fields.
+func (d *Defaulter) SetWithReport(ptr interface{}) (Report, error) {
+	w := d.walker()
+	w.reportIndex = make(map[string]int)
+	err := w.set(ptr)
+	if err == nil && len(w.errs) > 0 {
+		err = w.errs
+	}
+	if err == nil {
+		err = w.validate(reflect.ValueOf(ptr).Elem())
+	}
+
+	report := make(Report, len(w.reports))
+	for i, r := range w.reports {
+		report[i] = FieldReport{Path: r.path, Origin: r.origin, Tag: r.tag, Value: r.value.Interface()}
+	}
+	return report, err
+}
+
+// reportEntry is a FieldReport holding the field to read its final value from.
+type reportEntry struct {
+	path, origin, tag string
+	value             reflect.Value
+}
+
+// record records the origin of the value of the current field.
+func (w *walker) record(field reflect.Value, tag, origin string) {
+	if w.origins == nil && w.reportIndex == nil {
+		return
+	}
+	path := w.path.dotted()
+	if w.origins != nil {
+		w.origins[path] = origin
+	}
+	if w.reportIndex != nil {
+		w.addReport(path, field, tag, origin)
+	}
+}
+
+// recordDefault records the origin of the value of the current field set from a default value,
+// which may be a zero value like `false`.
+func (w *walker) recordDefault(field reflect.Value, tag string) {
+	if tag != "" {
+		w.record(field, tag, DefaultOrigin)
+	}
+}
+
+// recordPreserved reports the current field whose value is kept as is,
+// unless it is already reported, e.g. as overridden by an environment variable.
+func (w *walker) recordPreserved(field reflect.Value, tag string) {
+	if w.reportIndex == nil || tag == "" {
+		return
+	}
+	path := w.path.dotted()
+	if _, ok := w.reportIndex[path]; !ok {
+		w.addReport(path, field, tag, PreservedOrigin)
+	}
+}
+
+func (w *walker) addReport(path string, field reflect.Value, tag, origin string) {
+	e := reportEntry{path: path, origin: origin, tag: tag, value: field}
+	if i, ok := w.reportIndex[path]; ok {
+		if e.tag == "" {
+			e.tag = w.reports[i].tag
+		}
+		w.reports[i] = e
+		return
+	}
+	w.reportIndex[path] = len(w.reports)
+	w.reports = append(w.reports, e)
+}
+
+// callSetterReported calls the setter implemented by v, a pointer to a struct,
+// and reports the fields it changes, compared like the changes returned by Plan.
+func (w *walker) callSetterReported(v interface{}) error {
+	s := reflect.ValueOf(v).Elem()
+	before := cloneValue(s)
+	if err := callSetter(v); err != nil {
+		return err
+	}
+	for _, f := range w.plans.planOf(s.Type()).fields {
+		field := s.Field(f.index)
+		if len(diff(before.Field(f.index), field)) > 0 {
+			w.pushField(f.name)
+			w.record(field, f.tag.value, SetterOrigin)
+			w.pop()
+		}
+	}
+	return nil
+}
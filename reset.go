@@ -0,0 +1,79 @@
+package defaults
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Reset sets default values to a struct referenced by a pointer like Set,
+// but overwrites the fields with default values regardless of their current values.
+// Fields without default values are kept as they are, except in structs reset as a whole.
+func Reset(ptr interface{}) error {
+	return defaultDefaulter.Reset(ptr)
+}
+
+// ResetField resets a field of a struct referenced by a pointer like Reset.
+// The field is named by a path of field names joined by dots, e.g. `DB.Pool.Size`,
+// and nil pointers to structs on the path are allocated.
+func ResetField(ptr interface{}, path string) error {
+	return defaultDefaulter.ResetField(ptr, path)
+}
+
+// Reset resets fields to their default values like the package-level Reset.
+func (d *Defaulter) Reset(ptr interface{}) error {
+	w := d.walker()
+	w.force = true
+	if err := w.set(ptr); err != nil {
+		return err
+	}
+	if len(w.errs) > 0 {
+		return w.errs
+	}
+	return nil
+}
+
+// ResetField resets a field to its default value like the package-level ResetField.
+func (d *Defaulter) ResetField(ptr interface{}, path string) error {
+	v, err := structOf(ptr)
+	if err != nil {
+		return err
+	}
+	w := d.walker()
+	w.force = true
+
+	names := strings.Split(path, ".")
+	for i, name := range names {
+		f := w.plans.planOf(v.Type()).field(name)
+		if f == nil {
+			return fmt.Errorf("%w: %s", ErrFieldNotFound, path)
+		}
+		w.pushField(name)
+		field := v.Field(f.index)
+		if i == len(names)-1 {
+			if err := w.setStructField(field, f); err != nil {
+				return err
+			}
+			break
+		}
+
+		for field.Kind() == reflect.Ptr {
+			if field.IsNil() {
+				field.Set(reflect.New(field.Type().Elem()))
+			}
+			w.pushDeref()
+			field = field.Elem()
+		}
+		if field.Kind() != reflect.Struct {
+			return fmt.Errorf("%w: %s", ErrFieldNotFound, path)
+		}
+		// the `envPrefix` tags of the fields on the path name the variable of the last one
+		w.tags = append(w.tags, f.tags)
+		v = field
+	}
+
+	if len(w.errs) > 0 {
+		return w.errs
+	}
+	return nil
+}
@@ -5,8 +5,39 @@ type Setter interface {
 	SetDefaults()
 }
 
-func callSetter(v interface{}) {
-	if ds, ok := v.(Setter); ok {
+// ErrorSetter is an interface for setting default values that can fail
+type ErrorSetter interface {
+	SetDefaults() error
+}
+
+// GeneratedSetter is implemented by types whose SetDefaults is generated by cmd/defaults-gen.
+// Set calls their SetDefaults instead of walking their fields with reflection.
+type GeneratedSetter interface {
+	Setter
+
+	// GeneratedDefaults returns the key of the struct tags SetDefaults was generated from.
+	GeneratedDefaults() string
+}
+
+// hasSetter reports whether callSetter calls a SetDefaults of v.
+func hasSetter(v interface{}) bool {
+	switch v.(type) {
+	case GeneratedSetter:
+		return false
+	case Setter, ErrorSetter:
+		return true
+	}
+	return false
+}
+
+func callSetter(v interface{}) error {
+	switch ds := v.(type) {
+	case GeneratedSetter:
+		// its fields are set by the walk or by calling SetDefaults directly
+	case Setter:
 		ds.SetDefaults()
+	case ErrorSetter:
+		return ds.SetDefaults()
 	}
+	return nil
 }
@@ -0,0 +1,311 @@
+package defaults
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"flag"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// DefaultOrigin is the origin of values set from default values of tags in Origins.
+const DefaultOrigin = "default"
+
+// Source supplies values overriding fields in Load.
+type Source interface {
+	// Name identifies the source in Origins.
+	Name() string
+
+	// Lookup returns the value of a field, which is parsed like a default value.
+	Lookup(f SourceField) (string, bool)
+}
+
+// SourceField is a field looked up in a Source.
+// Fields in elements of slices, arrays and maps are not looked up.
+type SourceField struct {
+	Path  string              // the names of the fields joined by dots, e.g. `DB.Host`
+	Names []string            // the names of the fields from the outermost one
+	Tags  []reflect.StructTag // the tags of the fields from the outermost one
+}
+
+// Origins maps the paths of fields to the origins of their values,
+// which are DefaultOrigin or the names of sources.
+// A path is rendered without dereferences of pointers, e.g. `DB.Replicas[0].Host`.
+type Origins map[string]string
+
+// Load sets default values to a struct referenced by a pointer like Set,
+// overriding its fields by sources, of which a later one takes precedence.
+// Each source overrides only the fields it supplies, even with zero values, and default values
+// are not applied over them. A nil pointer to a struct is allocated if a source supplies any of its fields,
+// and the other fields of the struct are set with their default values.
+// The struct is walked once, so setters are called once after the fields are overridden.
+func Load(ptr interface{}, sources ...Source) (Origins, error) {
+	return defaultDefaulter.Load(ptr, sources...)
+}
+
+// Load sets default values and overrides fields by sources like the package-level Load.
+func (d *Defaulter) Load(ptr interface{}, sources ...Source) (Origins, error) {
+	w := d.walker()
+	w.origins = make(Origins)
+	if len(sources) > 0 {
+		w.sources = sources
+	}
+	if err := w.set(ptr); err != nil {
+		return w.origins, err
+	}
+	if len(w.errs) > 0 {
+		return w.origins, w.errs
+	}
+	return w.origins, w.validate(reflect.ValueOf(ptr).Elem())
+}
+
+// setStructField sets a field of a struct, overriding it by the sources of the walk if any.
+func (w *walker) setStructField(field reflect.Value, f *fieldPlan) error {
+	if w.sources == nil && !w.env {
+		return w.setField(field, f.tag)
+	}
+
+	w.tags = append(w.tags, f.tags)
+	defer func() { w.tags = w.tags[:len(w.tags)-1] }()
+
+	names, ok := w.path.fieldNames()
+	if w.sources == nil || !ok {
+		return w.setField(field, f.tag)
+	}
+	if !isDescendable(field.Type()) {
+		// an overridden field is not set any further, so that its default value is not applied over it
+		if overridden, err := w.overrideBySources(field, f.tag, names); err != nil || overridden {
+			return err
+		}
+		return w.setField(field, f.tag)
+	}
+
+	if err := w.setField(field, f.tag); err != nil {
+		return err
+	}
+	if field.Kind() == reflect.Ptr && field.IsNil() && w.supplied(field.Type().Elem(), names, make(map[reflect.Type]bool)) {
+		field.Set(reflect.New(field.Type().Elem()))
+		w.pushDeref()
+		err := w.setField(field.Elem(), fieldTag{})
+		w.pop()
+		return err
+	}
+	return nil
+}
+
+// overrideBySources overrides a field by the last source supplying it, and reports whether it was overridden.
+func (w *walker) overrideBySources(field reflect.Value, tag fieldTag, names []string) (bool, error) {
+	sf := SourceField{Path: strings.Join(names, "."), Names: names, Tags: w.tags}
+	for i := len(w.sources) - 1; i >= 0; i-- {
+		if val, ok := w.sources[i].Lookup(sf); ok {
+			return w.override(field, tag, val, w.sources[i].Name())
+		}
+	}
+	return false, nil
+}
+
+// supplied reports whether any source supplies a field of a struct type, whose fields are named after names.
+func (w *walker) supplied(t reflect.Type, names []string, seen map[reflect.Type]bool) bool {
+	if seen[t] {
+		return false
+	}
+	seen[t] = true
+	defer delete(seen, t)
+
+	plan := w.plans.planOf(t)
+	for i := range plan.fields {
+		f := &plan.fields[i]
+		ft := t.Field(f.index).Type
+		names := append(names[:len(names):len(names)], f.name)
+		w.tags = append(w.tags, f.tags)
+		var ok bool
+		if isDescendable(ft) {
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			ok = w.supplied(ft, names, seen)
+		} else {
+			sf := SourceField{Path: strings.Join(names, "."), Names: names, Tags: w.tags}
+			for _, src := range w.sources {
+				if _, ok = src.Lookup(sf); ok {
+					break
+				}
+			}
+		}
+		w.tags = w.tags[:len(w.tags)-1]
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+// isDescendable reports whether the fields of a struct of a type, or referenced by a pointer type,
+// are looked up in sources instead of the struct itself.
+func isDescendable(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || t == timeType {
+		return false
+	}
+	p := reflect.PtrTo(t)
+	return !p.Implements(textUnmarshalerType) && !p.Implements(jsonUnmarshalerType)
+}
+
+var (
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+)
+
+// MapSource returns a Source supplying values by the paths of fields, e.g. `DB.Host`.
+func MapSource(name string, values map[string]string) Source {
+	return &mapSource{name: name, values: values}
+}
+
+type mapSource struct {
+	name   string
+	values map[string]string
+}
+
+func (s *mapSource) Name() string { return s.name }
+
+func (s *mapSource) Lookup(f SourceField) (string, bool) {
+	val, ok := s.values[f.Path]
+	return val, ok
+}
+
+// EnvSource returns a Source supplying the environment variables named by `env` tags
+// like the Env option. os.LookupEnv is used if lookup is nil.
+func EnvSource(prefix string, lookup func(key string) (string, bool)) Source {
+	if lookup == nil {
+		lookup = os.LookupEnv
+	}
+	return &envSource{prefix: prefix, lookup: lookup}
+}
+
+type envSource struct {
+	prefix string
+	lookup func(key string) (string, bool)
+}
+
+func (s *envSource) Name() string { return envName }
+
+func (s *envSource) Lookup(f SourceField) (string, bool) {
+	key, ok := envKey(s.prefix, f.Tags)
+	if !ok {
+		return "", false
+	}
+	return s.lookup(key)
+}
+
+// FlagSource returns a Source supplying the values of the flags named by `flag` tags.
+// Only the flags set on the command line are supplied.
+func FlagSource(fs *flag.FlagSet) Source {
+	return &flagSource{fs: fs}
+}
+
+type flagSource struct {
+	fs *flag.FlagSet
+}
+
+func (s *flagSource) Name() string { return "flag" }
+
+func (s *flagSource) Lookup(f SourceField) (string, bool) {
+	name := f.Tags[len(f.Tags)-1].Get("flag")
+	if name == "" {
+		return "", false
+	}
+	var val string
+	var ok bool
+	s.fs.Visit(func(fl *flag.Flag) {
+		if fl.Name == name {
+			val, ok = fl.Value.String(), true
+		}
+	})
+	return val, ok
+}
+
+// JSONSource returns a Source supplying the values of a JSON object.
+// Fields are matched by their `json` tags or names like encoding/json,
+// and objects and arrays are supplied as JSON.
+func JSONSource(name string, data []byte) (Source, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var obj map[string]interface{}
+	if err := dec.Decode(&obj); err != nil {
+		return nil, err
+	}
+	return &jsonSource{name: name, obj: obj}, nil
+}
+
+// JSONFileSource returns a Source supplying the values of a JSON file named by its path.
+func JSONFileSource(path string) (Source, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return JSONSource(path, data)
+}
+
+type jsonSource struct {
+	name string
+	obj  map[string]interface{}
+}
+
+func (s *jsonSource) Name() string { return s.name }
+
+func (s *jsonSource) Lookup(f SourceField) (string, bool) {
+	var val interface{} = s.obj
+	for i, name := range f.Names {
+		obj, ok := val.(map[string]interface{})
+		if !ok {
+			return "", false
+		}
+		tag := f.Tags[i].Get("json")
+		if tag == "-" {
+			// ignored by encoding/json
+			return "", false
+		}
+		if tagged, _, _ := strings.Cut(tag, ","); tagged != "" {
+			name = tagged
+		}
+		if val, ok = lookupJSONKey(obj, name); !ok {
+			return "", false
+		}
+	}
+
+	switch val := val.(type) {
+	case nil:
+		return "", false
+	case string:
+		return val, true
+	case json.Number:
+		return val.String(), true
+	case bool:
+		if val {
+			return "true", true
+		}
+		return "false", true
+	}
+	data, err := json.Marshal(val)
+	if err != nil {
+		return "", false
+	}
+	return string(data), true
+}
+
+// lookupJSONKey looks up a key of a JSON object, preferring an exact match like encoding/json.
+func lookupJSONKey(obj map[string]interface{}, name string) (interface{}, bool) {
+	if val, ok := obj[name]; ok {
+		return val, true
+	}
+	for key, val := range obj {
+		if strings.EqualFold(key, name) {
+			return val, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,85 @@
+package defaults
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+)
+
+// setTime sets a time.Time field with one of the following forms:
+//
+//   - `now` or `today`, optionally followed by a signed duration, e.g. `now+24h`, `today-1h`
+//   - Unix epoch seconds, e.g. `1700000000`
+//   - a time formatted in the layout given by the `layout` tag, or RFC 3339 by default
+func (w *walker) setTime(field reflect.Value, tag fieldTag) error {
+	if tag.value == "" {
+		return nil
+	}
+	val, err := w.parseTime(tag.value, tag.layout)
+	if err != nil {
+		return err
+	}
+	field.Set(reflect.ValueOf(val))
+	return nil
+}
+
+func (w *walker) parseTime(s, layout string) (time.Time, error) {
+	for _, base := range []string{"now", "today"} {
+		if !strings.HasPrefix(s, base) {
+			continue
+		}
+		t := w.currentTime()
+		if base == "today" {
+			y, m, d := t.Date()
+			t = time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+		}
+		if offset := s[len(base):]; offset != "" {
+			d, err := time.ParseDuration(offset)
+			if err != nil {
+				return time.Time{}, err
+			}
+			t = t.Add(d)
+		}
+		return t, nil
+	}
+
+	if layout != "" {
+		return time.Parse(layout, s)
+	}
+	if sec, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(sec, 0), nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// isRelativeTime reports whether a default value of a type is relative to the current time, e.g. `now+1h`,
+// so that it differs each time it is set.
+func isRelativeTime(t reflect.Type, defaultVal string) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t == timeType && (strings.HasPrefix(defaultVal, "now") || strings.HasPrefix(defaultVal, "today"))
+}
+
+// hasRelativeTime reports whether the field of a struct type at an index has a default value relative to the current time.
+func (p *structPlan) hasRelativeTime(t reflect.Type, index int) bool {
+	for i := range p.fields {
+		if p.fields[i].index == index {
+			return isRelativeTime(t.Field(index).Type, p.fields[i].tag.value)
+		}
+	}
+	return false
+}
+
+func (w *walker) currentTime() time.Time {
+	if w.now != nil {
+		return w.now()
+	}
+	return time.Now()
+}
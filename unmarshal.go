@@ -0,0 +1,696 @@
+package defaults
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// UnmarshalJSON decodes JSON into a value referenced by a pointer like json.Unmarshal,
+// after setting default values to it. Every struct created while decoding,
+// e.g. an element of a slice, a value of a map or a struct referenced by a new pointer,
+// is also set with its default values before the JSON is decoded into it.
+// Setters are called after the JSON is decoded, from the innermost structs.
+// Like json.Unmarshal, members of objects are decoded in order, and an error like a type mismatch
+// is returned after decoding the rest, with the field it occurred in.
+func UnmarshalJSON(data []byte, v interface{}) error {
+	return defaultDefaulter.Unmarshal(data, v)
+}
+
+// Unmarshal decodes JSON after setting default values like UnmarshalJSON.
+func (d *Defaulter) Unmarshal(data []byte, v interface{}) error {
+	return (&jsonDecoder{w: d.walker()}).unmarshal(data, v)
+}
+
+// Decoder reads and decodes JSON values from an input stream like json.Decoder,
+// setting default values like UnmarshalJSON.
+type Decoder struct {
+	dec *json.Decoder
+	d   *Defaulter
+	jd  jsonDecoder
+}
+
+// NewDecoder returns a new Decoder reading from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return defaultDefaulter.NewDecoder(r)
+}
+
+// NewDecoder returns a new Decoder reading from r, which sets default values with the options of d.
+func (d *Defaulter) NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{dec: json.NewDecoder(r), d: d}
+}
+
+// UseNumber makes the Decoder decode a number into an interface{} as a json.Number.
+func (dec *Decoder) UseNumber() {
+	dec.jd.useNumber = true
+}
+
+// DisallowUnknownFields makes the Decoder return an error for an object key matching no field.
+func (dec *Decoder) DisallowUnknownFields() {
+	dec.jd.disallowUnknownFields = true
+}
+
+// More reports whether there is another element in the current array or object being parsed.
+func (dec *Decoder) More() bool {
+	return dec.dec.More()
+}
+
+// Decode reads the next JSON value from its input and decodes it into v like UnmarshalJSON.
+func (dec *Decoder) Decode(v interface{}) error {
+	var raw json.RawMessage
+	if err := dec.dec.Decode(&raw); err != nil {
+		return err
+	}
+	jd := dec.jd
+	jd.w = dec.d.walker()
+	return jd.unmarshal(raw, v)
+}
+
+// jsonDecoder decodes JSON value by value to set default values to the values it creates.
+type jsonDecoder struct {
+	w                     *walker
+	useNumber             bool
+	disallowUnknownFields bool
+	savedErr              error        // the first error not stopping decoding
+	root                  reflect.Type // the type of the value decoded
+	errStruct             reflect.Type // the innermost struct whose field is being decoded
+	errPath               []string     // the path of the value being decoded reported by errors
+}
+
+func (jd *jsonDecoder) unmarshal(data []byte, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return &json.InvalidUnmarshalError{Type: reflect.TypeOf(v)}
+	}
+	if !json.Valid(data) {
+		// let encoding/json report the syntax error
+		return json.Unmarshal(data, v)
+	}
+	trimmed := bytes.TrimSpace(data)
+	offset := int64(len(data) - len(bytes.TrimLeft(data, " \t\r\n")))
+	jd.root = rv.Elem().Type()
+	jd.w.noSetters = true
+	if err := jd.setDefaults(rv.Elem()); err != nil {
+		return err
+	}
+	if err := jd.decode(rv.Elem(), trimmed, offset); err != nil {
+		return err
+	}
+	jd.w.noSetters = false
+	if err := jd.w.callSetters(rv.Elem()); err != nil {
+		return err
+	}
+	if jd.savedErr != nil {
+		return jd.savedErr
+	}
+	if len(jd.w.errs) > 0 {
+		return jd.w.errs
+	}
+	return jd.w.validate(rv.Elem())
+}
+
+// callSetters calls the setters of the structs in v from the innermost ones.
+func (w *walker) callSetters(v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return nil
+		}
+		w.pushDeref()
+		defer w.pop()
+		return w.callSetters(v.Elem())
+	case reflect.Struct:
+		if v.Type() == timeType {
+			return nil
+		}
+		plan := w.plans.planOf(v.Type())
+		for i := range plan.fields {
+			w.pushField(plan.fields[i].name)
+			err := w.callSetters(v.Field(plan.fields[i].index))
+			w.pop()
+			if err != nil {
+				return err
+			}
+		}
+		return w.callSetter(v.Addr().Interface())
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			w.pushIndex(i)
+			err := w.callSetters(v.Index(i))
+			w.pop()
+			if err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			elem := iter.Value()
+			if elem.Kind() != reflect.Ptr {
+				// a value of a map is not addressable
+				elem = reflect.New(elem.Type()).Elem()
+				elem.Set(iter.Value())
+			}
+			w.pushKey(iter.Key())
+			err := w.callSetters(elem)
+			w.pop()
+			if err != nil {
+				return err
+			}
+			if elem.Kind() != reflect.Ptr {
+				v.SetMapIndex(iter.Key(), elem)
+			}
+		}
+	}
+	return nil
+}
+
+// setDefaults sets default values to a value created while decoding.
+func (jd *jsonDecoder) setDefaults(v reflect.Value) error {
+	return jd.w.setField(v, fieldTag{})
+}
+
+// decode decodes a valid JSON value into v, where offset is the position of data in the input.
+func (jd *jsonDecoder) decode(v reflect.Value, data []byte, offset int64) error {
+	if isUnmarshaler(v) {
+		return jd.decodeValue(v, data, offset, false)
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if string(data) == "null" {
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+		if v.IsNil() {
+			p := reflect.New(v.Type().Elem())
+			if err := jd.setDefaults(p.Elem()); err != nil {
+				return err
+			}
+			v.Set(p)
+		}
+		return jd.decode(v.Elem(), data, offset)
+
+	case reflect.Struct:
+		if data[0] == '{' {
+			return jd.decodeStruct(v, data, offset)
+		}
+
+	case reflect.Slice:
+		if data[0] == '[' {
+			elems, err := splitJSON(data, offset)
+			if err != nil {
+				return err
+			}
+			s := reflect.MakeSlice(v.Type(), len(elems), len(elems))
+			v.Set(s)
+			for i, elem := range elems {
+				if err := jd.decodeElem(s.Index(i), elem, strconv.Itoa(i)); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+	case reflect.Array:
+		if data[0] == '[' {
+			elems, err := splitJSON(data, offset)
+			if err != nil {
+				return err
+			}
+			for i := 0; i < v.Len(); i++ {
+				// elements missing in JSON are zeroed like encoding/json, then set with default values
+				v.Index(i).Set(reflect.Zero(v.Type().Elem()))
+				if i >= len(elems) {
+					if err := jd.setDefaults(v.Index(i)); err != nil {
+						return err
+					}
+					continue
+				}
+				if err := jd.decodeElem(v.Index(i), elems[i], strconv.Itoa(i)); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+	case reflect.Map:
+		if data[0] == '{' {
+			return jd.decodeMap(v, data, offset)
+		}
+	}
+	return jd.decodeValue(v, data, offset, false)
+}
+
+// decodeNew decodes JSON into v holding a zero value, after setting its default values.
+func (jd *jsonDecoder) decodeNew(v reflect.Value, data []byte, offset int64) error {
+	if err := jd.setDefaults(v); err != nil {
+		return err
+	}
+	return jd.decode(v, data, offset)
+}
+
+// decodeElem decodes an element of an array or a value of a map named by key in the paths of errors.
+func (jd *jsonDecoder) decodeElem(v reflect.Value, elem jsonValue, key string) error {
+	if !jsonErrorPaths() {
+		return jd.decodeNew(v, elem.data, elem.offset)
+	}
+	n := len(jd.errPath)
+	jd.errPath = append(jd.errPath, key)
+	if err := jd.decodeNew(v, elem.data, elem.offset); err != nil {
+		return err
+	}
+	jd.errPath = jd.errPath[:n]
+	return nil
+}
+
+// decodeStruct decodes the members of a JSON object into the fields of v in order,
+// keeping the context of errors like encoding/json.
+func (jd *jsonDecoder) decodeStruct(v reflect.Value, data []byte, offset int64) error {
+	members, err := splitJSON(data, offset)
+	if err != nil {
+		return err
+	}
+	fields := jsonFieldsOf(v.Type())
+	for _, m := range members {
+		f := fields.match(m.key)
+		if f == nil {
+			if jd.disallowUnknownFields {
+				jd.saveError(fmt.Errorf("json: unknown field %q", m.key))
+			}
+			continue
+		}
+		errStruct, errPath := jd.errStruct, len(jd.errPath)
+		field, ok, err := jd.fieldByIndex(v, f.index)
+		if err != nil {
+			return err
+		}
+		jd.errStruct = v.Type()
+		jd.errPath = append(jd.errPath, f.name)
+		if ok {
+			if f.quoted {
+				err = jd.decodeValue(field, m.data, m.offset, true)
+			} else {
+				err = jd.decode(field, m.data, m.offset)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		jd.errStruct, jd.errPath = errStruct, jd.errPath[:errPath]
+	}
+	return nil
+}
+
+// fieldByIndex returns a nested field, allocating embedded structs referenced by nil pointers,
+// and adds the names of the embedded structs to the paths of errors unless they are left out.
+// It reports false if the field cannot be set, which is skipped like encoding/json.
+func (jd *jsonDecoder) fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool, error) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if !v.CanSet() {
+					jd.saveError(fmt.Errorf("json: cannot set embedded pointer to unexported struct: %v", v.Type().Elem()))
+					return reflect.Value{}, false, nil
+				}
+				p := reflect.New(v.Type().Elem())
+				if err := jd.setDefaults(p.Elem()); err != nil {
+					return reflect.Value{}, false, err
+				}
+				v.Set(p)
+			}
+			v = v.Elem()
+		}
+		if i < len(index)-1 && !jsonErrorPaths() {
+			jd.errPath = append(jd.errPath, v.Type().Field(x).Name)
+		}
+		v = v.Field(x)
+	}
+	return v, true, nil
+}
+
+func (jd *jsonDecoder) decodeMap(v reflect.Value, data []byte, offset int64) error {
+	t := v.Type()
+	if !isMapKey(t.Key()) {
+		jd.saveError(&json.UnmarshalTypeError{Value: "object", Type: t, Offset: offset + 1})
+		return nil
+	}
+	members, err := splitJSON(data, offset)
+	if err != nil {
+		return err
+	}
+	if v.IsNil() {
+		v.Set(reflect.MakeMap(t))
+	}
+	for _, m := range members {
+		elem := reflect.New(t.Elem()).Elem()
+		if err := jd.decodeElem(elem, m, m.key); err != nil {
+			return err
+		}
+		k, ok, err := jd.mapKey(t.Key(), m)
+		if err != nil {
+			return err
+		}
+		if ok {
+			v.SetMapIndex(k, elem)
+		}
+	}
+	return nil
+}
+
+// valueWrappers holds the struct types wrapping values decoded by encoding/json.
+var valueWrappers sync.Map // map[valueWrapperKey]reflect.Type
+
+type valueWrapperKey struct {
+	typ    reflect.Type
+	quoted bool
+}
+
+// valueWrapperOf returns a struct type with a field `v` of a type, followed by a bool field `done`.
+func valueWrapperOf(typ reflect.Type, quoted bool) reflect.Type {
+	key := valueWrapperKey{typ: typ, quoted: quoted}
+	if t, ok := valueWrappers.Load(key); ok {
+		return t.(reflect.Type)
+	}
+	tag := `json:"v"`
+	if quoted {
+		tag = `json:"v,string"`
+	}
+	t := reflect.StructOf([]reflect.StructField{
+		{Name: "V", Type: typ, Tag: reflect.StructTag(tag)},
+		{Name: "Done", Type: reflect.TypeOf(false), Tag: `json:"done"`},
+	})
+	actual, _ := valueWrappers.LoadOrStore(key, t)
+	return actual.(reflect.Type)
+}
+
+// valuePrefix precedes a value decoded in a wrapper.
+const valuePrefix = `{"v":`
+
+// decodeValue decodes JSON into v with encoding/json, in the `string` option if quoted.
+// The value is decoded in a wrapper followed by the field `done`, which encoding/json decodes
+// only if an error decoding the value is saved to decode the rest, and not returned at once.
+func (jd *jsonDecoder) decodeValue(v reflect.Value, data []byte, offset int64, quoted bool) error {
+	w := reflect.New(valueWrapperOf(v.Type(), quoted)).Elem()
+	w.Field(0).Set(v)
+	in := make([]byte, 0, len(valuePrefix)+len(data)+len(`,"done":true}`))
+	in = append(append(append(in, valuePrefix...), data...), `,"done":true}`...)
+
+	dec := json.NewDecoder(bytes.NewReader(in))
+	if jd.useNumber {
+		dec.UseNumber()
+	}
+	if jd.disallowUnknownFields {
+		dec.DisallowUnknownFields()
+	}
+	err := dec.Decode(w.Addr().Interface())
+	v.Set(w.Field(0))
+	if err == nil {
+		return nil
+	}
+	if e, ok := err.(*json.UnmarshalTypeError); ok {
+		// remove the context of the wrapper
+		e.Offset += offset - int64(len(valuePrefix))
+		e.Field = strings.TrimPrefix(strings.TrimPrefix(e.Field, "v"), ".")
+	}
+	if !w.Field(1).Bool() {
+		return jd.addErrorContext(err)
+	}
+	jd.saveError(err)
+	return nil
+}
+
+// saveError saves the first error not stopping decoding, which is returned at the end like encoding/json.
+func (jd *jsonDecoder) saveError(err error) {
+	if jd.savedErr == nil {
+		jd.savedErr = jd.addErrorContext(err)
+	}
+}
+
+// addErrorContext adds the struct and the path of the value being decoded to an UnmarshalTypeError.
+func (jd *jsonDecoder) addErrorContext(err error) error {
+	e, ok := err.(*json.UnmarshalTypeError)
+	if !ok || len(jd.errPath) == 0 {
+		return err
+	}
+	if jsonErrorPaths() {
+		e.Struct = jd.root.Name()
+	} else if e.Struct == "" {
+		e.Struct = jd.errStruct.Name()
+	}
+	path := jd.errPath
+	if e.Field != "" {
+		path = append(path[:len(path):len(path)], e.Field)
+	}
+	e.Field = strings.Join(path, ".")
+	return err
+}
+
+var (
+	jsonErrorPathsOnce  sync.Once
+	jsonErrorPathsValue bool
+)
+
+// jsonErrorPaths reports whether encoding/json reports errors with the name of the type decoded
+// and the path from it including indexes and keys, as it does when implemented by encoding/json/v2.
+// Otherwise errors have the innermost struct and the names of fields.
+func jsonErrorPaths() bool {
+	jsonErrorPathsOnce.Do(func() {
+		var v struct{ A []struct{ B int } }
+		e, ok := json.Unmarshal([]byte(`{"A": [{"B": ""}]}`), &v).(*json.UnmarshalTypeError)
+		jsonErrorPathsValue = ok && e.Field == "A.0.B"
+	})
+	return jsonErrorPathsValue
+}
+
+func isUnmarshaler(v reflect.Value) bool {
+	if _, ok := v.Addr().Interface().(json.Unmarshaler); ok {
+		return true
+	}
+	_, ok := v.Addr().Interface().(encoding.TextUnmarshaler)
+	return ok && v.Kind() != reflect.Struct && v.Kind() != reflect.Map && v.Kind() != reflect.Slice
+}
+
+// isMapKey reports whether encoding/json decodes keys of JSON objects into a type.
+func isMapKey(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	}
+	return reflect.PtrTo(t).Implements(textUnmarshalerType)
+}
+
+// mapKey converts the key of a member of a JSON object to a key of a map like encoding/json,
+// and reports false if it cannot be converted, which is saved as an error.
+func (jd *jsonDecoder) mapKey(t reflect.Type, m jsonValue) (reflect.Value, bool, error) {
+	k := reflect.New(t)
+	if u, ok := k.Interface().(encoding.TextUnmarshaler); ok {
+		if err := u.UnmarshalText([]byte(m.key)); err != nil {
+			return reflect.Value{}, false, jd.addErrorContext(err)
+		}
+		return k.Elem(), true, nil
+	}
+	switch t.Kind() {
+	case reflect.String:
+		k.Elem().SetString(m.key)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(m.key, 10, 64)
+		if err != nil || k.Elem().OverflowInt(n) {
+			jd.saveKeyError(t, m)
+			return reflect.Value{}, false, nil
+		}
+		k.Elem().SetInt(n)
+	default:
+		n, err := strconv.ParseUint(m.key, 10, 64)
+		if err != nil || k.Elem().OverflowUint(n) {
+			jd.saveKeyError(t, m)
+			return reflect.Value{}, false, nil
+		}
+		k.Elem().SetUint(n)
+	}
+	return k.Elem(), true, nil
+}
+
+// saveKeyError saves an error for a key of a JSON object not converted to a key of a map.
+func (jd *jsonDecoder) saveKeyError(t reflect.Type, m jsonValue) {
+	err := &json.UnmarshalTypeError{Value: "number " + m.key, Type: t, Offset: m.keyOffset + 1}
+	if !jsonErrorPaths() {
+		jd.saveError(err)
+		return
+	}
+	// the error is of the key after it is read
+	err.Offset = m.keyEnd
+	n := len(jd.errPath)
+	jd.errPath = append(jd.errPath, m.key)
+	jd.saveError(err)
+	jd.errPath = jd.errPath[:n]
+}
+
+// jsonValue is an element of a JSON array or a member of a JSON object.
+type jsonValue struct {
+	key       string // the key of a member
+	keyOffset int64  // the position of the key of a member in the input
+	keyEnd    int64  // the position following the key of a member
+	data      []byte
+	offset    int64 // the position of data in the input
+}
+
+// splitJSON returns the elements of a valid JSON array or the members of a valid JSON object in order,
+// where offset is the position of data in the input.
+func splitJSON(data []byte, offset int64) ([]jsonValue, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	var vals []jsonValue
+	for dec.More() {
+		var v jsonValue
+		if data[0] == '{' {
+			start := dec.InputOffset()
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			v.key = key.(string)
+			v.keyOffset = offset + start + int64(bytes.IndexByte(data[start:], '"'))
+			v.keyEnd = offset + dec.InputOffset()
+		}
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, err
+		}
+		end := dec.InputOffset()
+		v.data = data[end-int64(len(raw)) : end]
+		v.offset = offset + end - int64(len(raw))
+		vals = append(vals, v)
+	}
+	return vals, nil
+}
+
+// jsonFieldCache holds the jsonFields of each struct type.
+var jsonFieldCache sync.Map // map[reflect.Type]jsonFields
+
+// jsonFields are the fields of a struct decoded by encoding/json.
+type jsonFields []jsonField
+
+type jsonField struct {
+	name   string
+	index  []int
+	tagged bool
+	quoted bool // the value is encoded in a JSON string with the `string` option
+}
+
+// match returns the field named by a key of a JSON object, preferring an exact match like encoding/json.
+func (fs jsonFields) match(key string) *jsonField {
+	for i := range fs {
+		if fs[i].name == key {
+			return &fs[i]
+		}
+	}
+	for i := range fs {
+		if strings.EqualFold(fs[i].name, key) {
+			return &fs[i]
+		}
+	}
+	return nil
+}
+
+// jsonFieldsOf returns the fields of a struct type visible to encoding/json,
+// resolving fields promoted from embedded structs by their depth.
+func jsonFieldsOf(t reflect.Type) jsonFields {
+	if fs, ok := jsonFieldCache.Load(t); ok {
+		return fs.(jsonFields)
+	}
+
+	var all jsonFields
+	var walk func(t reflect.Type, index []int, seen map[reflect.Type]bool)
+	walk = func(t reflect.Type, index []int, seen map[reflect.Type]bool) {
+		if seen[t] {
+			return
+		}
+		seen[t] = true
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			tag := sf.Tag.Get("json")
+			if tag == "-" {
+				continue
+			}
+			name, opts, _ := strings.Cut(tag, ",")
+			idx := append(append([]int(nil), index...), i)
+			if sf.Anonymous && name == "" {
+				ft := sf.Type
+				if ft.Kind() == reflect.Ptr {
+					ft = ft.Elem()
+				}
+				if ft.Kind() == reflect.Struct {
+					walk(ft, idx, seen)
+					continue
+				}
+			}
+			if !sf.IsExported() {
+				continue
+			}
+			f := jsonField{name: name, index: idx, tagged: name != "", quoted: hasOption(opts, "string") && isQuotable(sf.Type)}
+			if name == "" {
+				f.name = sf.Name
+			}
+			all = append(all, f)
+		}
+	}
+	walk(t, nil, make(map[reflect.Type]bool))
+
+	// like encoding/json, the shallowest field with a name wins,
+	// unless there is no single one or no single tagged one among them
+	var fs jsonFields
+	for _, f := range all {
+		dominant := true
+		for _, o := range all {
+			if o.name != f.name || reflect.DeepEqual(o.index, f.index) {
+				continue
+			}
+			if len(o.index) < len(f.index) || len(o.index) == len(f.index) && (o.tagged || !f.tagged) {
+				dominant = false
+				break
+			}
+		}
+		if dominant {
+			fs = append(fs, f)
+		}
+	}
+	actual, _ := jsonFieldCache.LoadOrStore(t, fs)
+	return actual.(jsonFields)
+}
+
+func hasOption(opts, name string) bool {
+	for opts != "" {
+		var opt string
+		opt, opts, _ = strings.Cut(opts, ",")
+		if opt == name {
+			return true
+		}
+	}
+	return false
+}
+
+// isQuotable reports whether the `string` option of encoding/json applies to a type.
+func isQuotable(t reflect.Type) bool {
+	if t.Name() == "" && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,281 @@
+package defaults
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Tags validating fields after default values are set.
+const (
+	requiredName = "required"
+	minName      = "min"
+	maxName      = "max"
+	oneofName    = "oneof"
+	regexpName   = "regexp"
+)
+
+// constraints are the values allowed for a field, declared by its tags.
+type constraints struct {
+	min, max string
+	oneof    []string
+	pattern  *regexp.Regexp
+	err      error // an error compiling the pattern
+}
+
+// constraintsOf returns the constraints declared by tags, or nil if there are none.
+func constraintsOf(tag reflect.StructTag) *constraints {
+	c := &constraints{min: tag.Get(minName), max: tag.Get(maxName)}
+	if opts := strings.Fields(tag.Get(oneofName)); len(opts) > 0 {
+		c.oneof = opts
+	}
+	if expr := tag.Get(regexpName); expr != "" {
+		c.pattern, c.err = regexp.Compile(expr)
+	}
+	if c.min == "" && c.max == "" && c.oneof == nil && c.pattern == nil && c.err == nil {
+		return nil
+	}
+	return c
+}
+
+// validate returns a FieldError for each field in v that is required but holds a zero value,
+// or whose default value or value violates its constraints.
+// It is called after default values, setters and overrides are applied, so any of them can supply the value.
+// Constraints are checked only with the Validate option.
+func (w *walker) validate(v reflect.Value) error {
+	c := w.plans.checksOf(v.Type())
+	if !w.validateFields {
+		c &^= checkConstraints
+	}
+	if c == 0 {
+		return nil
+	}
+	w.errs = nil
+	w.path = w.path[:0]
+	w.validateValue(v)
+	if len(w.errs) > 0 {
+		return w.errs
+	}
+	return nil
+}
+
+// checks are the kinds of validation fields of a type can have.
+type checks uint8
+
+const (
+	checkRequired checks = 1 << iota
+	checkConstraints
+)
+
+// checksOf returns the kinds of validation the fields in a value of a type can have.
+func (c *planCache) checksOf(t reflect.Type) checks {
+	if v, ok := c.validated.Load(t); ok {
+		return v.(checks)
+	}
+	v := c.findChecks(t, make(map[reflect.Type]bool))
+	c.validated.Store(t, v)
+	return v
+}
+
+func (c *planCache) findChecks(t reflect.Type, seen map[reflect.Type]bool) checks {
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+		return c.findChecks(t.Elem(), seen)
+	case reflect.Struct:
+		if seen[t] || t == timeType {
+			return 0
+		}
+		seen[t] = true
+		var v checks
+		for _, f := range c.planOf(t).fields {
+			if f.required {
+				v |= checkRequired
+			}
+			if f.constraints != nil {
+				v |= checkConstraints
+			}
+			v |= c.findChecks(t.Field(f.index).Type, seen)
+		}
+		return v
+	}
+	return 0
+}
+
+func (w *walker) validateValue(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() {
+			w.pushDeref()
+			w.validateValue(v.Elem())
+			w.pop()
+		}
+	case reflect.Struct:
+		if v.Type() == timeType {
+			return
+		}
+		plan := w.plans.planOf(v.Type())
+		for i := range plan.fields {
+			w.pushField(plan.fields[i].name)
+			w.validateField(v.Field(plan.fields[i].index), &plan.fields[i])
+			w.pop()
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			w.pushIndex(i)
+			w.validateValue(v.Index(i))
+			w.pop()
+		}
+	case reflect.Map:
+		for _, k := range sortedMapKeys(v, v) {
+			w.pushKey(k)
+			w.validateValue(v.MapIndex(k))
+			w.pop()
+		}
+	}
+}
+
+func (w *walker) validateField(field reflect.Value, f *fieldPlan) {
+	if f.required && isInitialValue(field) {
+		w.errs = append(w.errs, &FieldError{Path: w.path.String(), Tag: f.tag.value, Type: field.Type(), Kind: ErrRequired, Err: ErrRequired})
+		return
+	}
+
+	if c := f.constraints; c != nil && w.validateFields {
+		// the default value is checked even if it is overridden, so that a bad one is caught early
+		def := reflect.Value{}
+		if f.tag.value != "" {
+			def = w.defaultOf(field.Type(), f.tag)
+			if err := c.check(def, w.int64AsDuration); err != nil {
+				w.errs = append(w.errs, newConstraintError(w.path.String(), f.tag.value, field.Type(), fmt.Errorf("default %w", err)))
+			}
+		}
+		// zero values are left to the required tag
+		if !isInitialValue(field) && !(def.IsValid() && reflect.DeepEqual(field.Interface(), def.Interface())) {
+			if err := c.check(field, w.int64AsDuration); err != nil {
+				w.errs = append(w.errs, newConstraintError(w.path.String(), f.tag.value, field.Type(), err))
+			}
+		}
+	}
+
+	w.validateValue(field)
+}
+
+// defaultOf returns the default value of a field as a new value of its type.
+func (w *walker) defaultOf(typ reflect.Type, tag fieldTag) reflect.Value {
+	dw := &walker{options: w.options, plans: w.plans}
+	dw.env = false
+	dw.allErrors = true
+	v := reflect.New(typ).Elem()
+	_ = dw.setField(v, tag)
+	return v
+}
+
+func newConstraintError(path, tag string, typ reflect.Type, err error) *FieldError {
+	return &FieldError{Path: path, Tag: tag, Type: typ, Kind: ErrConstraint, Err: err}
+}
+
+// check returns an error if v violates the constraints.
+// min and max bound numbers, or the lengths of strings, slices, arrays and maps.
+func (c *constraints) check(v reflect.Value, int64AsDuration bool) error {
+	if c.err != nil {
+		return fmt.Errorf("invalid %s: %w", regexpName, c.err)
+	}
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	if c.min != "" {
+		if n, err := compareBound(v, c.min, int64AsDuration); err != nil {
+			return fmt.Errorf("invalid %s: %w", minName, err)
+		} else if n < 0 {
+			return fmt.Errorf("value %s is less than %s %s", describe(v), minName, c.min)
+		}
+	}
+	if c.max != "" {
+		if n, err := compareBound(v, c.max, int64AsDuration); err != nil {
+			return fmt.Errorf("invalid %s: %w", maxName, err)
+		} else if n > 0 {
+			return fmt.Errorf("value %s is greater than %s %s", describe(v), maxName, c.max)
+		}
+	}
+
+	if c.oneof != nil {
+		found := false
+		for _, opt := range c.oneof {
+			p := parseValue(v.Type(), opt, int64AsDuration)
+			if p.err != nil {
+				return fmt.Errorf("invalid %s: %w", oneofName, p.err)
+			}
+			if p.val.IsValid() && reflect.DeepEqual(v.Interface(), p.val.Interface()) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("value %v is not one of %s", v.Interface(), strings.Join(c.oneof, ", "))
+		}
+	}
+
+	if c.pattern != nil {
+		if v.Kind() != reflect.String {
+			return fmt.Errorf("%w: %s for %s", ErrUnsupportedKind, regexpName, v.Type())
+		}
+		if !c.pattern.MatchString(v.String()) {
+			return fmt.Errorf("value %q does not match %s", v.String(), c.pattern)
+		}
+	}
+	return nil
+}
+
+// compareBound compares v with a bound parsed for its type, or its length with a bound.
+func compareBound(v reflect.Value, bound string, int64AsDuration bool) (int, error) {
+	switch v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+		n, err := strconv.Atoi(bound)
+		if err != nil {
+			return 0, err
+		}
+		return compare(v.Len(), n), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		p := parseValue(v.Type(), bound, int64AsDuration)
+		if p.err != nil {
+			return 0, p.err
+		}
+		switch v.Kind() {
+		case reflect.Float32, reflect.Float64:
+			return compare(v.Float(), p.val.Float()), nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			return compare(v.Uint(), p.val.Uint()), nil
+		}
+		return compare(v.Int(), p.val.Int()), nil
+	}
+	return 0, fmt.Errorf("%w: %s", ErrUnsupportedKind, v.Type())
+}
+
+func compare[T int | int64 | uint64 | float64](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// describe returns v to be shown in an error for a bound, with its length if it has one.
+func describe(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.String:
+		return fmt.Sprintf("%q of length %d", v.String(), v.Len())
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return fmt.Sprintf("of length %d", v.Len())
+	}
+	return fmt.Sprint(v.Interface())
+}